@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/nishanths/lyft-go"
+)
+
+// describeError returns a message for an error returned by a lyft.Client
+// method, suitable for display to the user. For a *lyft.StatusError with a
+// common reason or status code, the message says what the user can do
+// about it. The Request-ID from h, which may be nil, is appended if present
+// to help with debugging. sandbox reports whether the request was made in
+// sandbox mode.
+func describeError(err error, h http.Header, sandbox bool) string {
+	se, ok := err.(*lyft.StatusError)
+	if !ok {
+		return err.Error()
+	}
+	msg := se.Error()
+	if f := friendlyMessage(se, sandbox); f != "" {
+		msg = fmt.Sprintf("%s (%s)", f, msg)
+	}
+	if id := lyft.RequestID(h); id != "" {
+		msg = fmt.Sprintf("%s [Request-ID: %s]", msg, id)
+	}
+	return msg
+}
+
+// friendlyMessage returns an actionable message for the error's reason or
+// status code, or an empty string if there isn't one. The message refers
+// to the internal file for the mode that sandbox specifies.
+func friendlyMessage(se *lyft.StatusError, sandbox bool) string {
+	reauth := fmt.Sprintf("remove %s to authorize again", internalPath(sandbox))
+
+	switch {
+	case se.Reason == lyft.InvalidToken || se.StatusCode == 401:
+		return "authorization is no longer valid; " + reauth
+	case se.Reason == lyft.InsufficientScope || se.StatusCode == 403:
+		return "the program isn't authorized to do this; " + reauth
+	case se.StatusCode == 404:
+		// Not only ride endpoints return 404, so don't assume a ride ID.
+		return "not found; check the command's arguments"
+	case se.StatusCode == 429:
+		return "too many requests; try again shortly"
+	case se.StatusCode >= 500:
+		return "the Lyft API is having trouble; try again later"
+	}
+	return ""
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/nishanths/lyft-go"
+)
+
+func statusError(code int, reason string) *lyft.StatusError {
+	se := &lyft.StatusError{StatusCode: code}
+	se.Reason = reason
+	return se
+}
+
+func TestFriendlyMessage(t *testing.T) {
+	tests := []struct {
+		err  *lyft.StatusError
+		want string // substring of the message; empty for no message
+	}{
+		{statusError(401, ""), "authorization is no longer valid"},
+		{statusError(400, lyft.InvalidToken), "authorization is no longer valid"},
+		{statusError(403, ""), "isn't authorized"},
+		{statusError(400, lyft.InsufficientScope), "isn't authorized"},
+		{statusError(404, ""), "not found"},
+		{statusError(429, ""), "too many requests"},
+		{statusError(500, ""), "having trouble"},
+		{statusError(503, ""), "having trouble"},
+		{statusError(400, "bad_request"), ""},
+	}
+	for _, tt := range tests {
+		got := friendlyMessage(tt.err, false)
+		if tt.want == "" {
+			if got != "" {
+				t.Errorf("%v: got %q, want no message", tt.err, got)
+			}
+			continue
+		}
+		if !strings.Contains(got, tt.want) {
+			t.Errorf("%v: got %q, want it to contain %q", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestDescribeError(t *testing.T) {
+	// Errors other than *lyft.StatusError are described as is.
+	if got := describeError(errors.New("dial tcp: timeout"), nil, false); got != "dial tcp: timeout" {
+		t.Errorf("got %q", got)
+	}
+
+	se := statusError(429, "")
+	if got, want := describeError(se, nil, false), "too many requests; try again shortly (status code=429)"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	h := make(http.Header)
+	h.Set("Request-ID", "abc123")
+	if got, want := describeError(se, h, false), "too many requests; try again shortly (status code=429) [Request-ID: abc123]"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// Without a friendly message, the error and the Request-ID are kept.
+	if got, want := describeError(statusError(400, "bad_request"), h, false), "bad_request: status code=400 [Request-ID: abc123]"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestFriendlyMessageMode(t *testing.T) {
+	tempHome(t)
+	se := statusError(401, "")
+	if got := friendlyMessage(se, false); !strings.Contains(got, internalPath(false)) {
+		t.Errorf("production: got %q, want it to contain %s", got, internalPath(false))
+	}
+	if got := friendlyMessage(se, true); !strings.Contains(got, internalPath(true)) {
+		t.Errorf("sandbox: got %q, want it to contain %s", got, internalPath(true))
+	}
+}
+
+func TestFriendlyMessageNotFound(t *testing.T) {
+	// 404s are also returned by endpoints without a ride ID, such as cost.
+	got := friendlyMessage(statusError(404, ""), false)
+	if strings.Contains(got, "ride ID") {
+		t.Errorf("got %q, want a message that doesn't assume a ride ID", got)
+	}
+}
@@ -0,0 +1,26 @@
+package main
+
+import "math"
+
+// Mean radius of the Earth, in meters.
+const earthRadius = 6371008.8
+
+// sameLocationThreshold is the distance, in meters, under which a ride's
+// start and end locations are considered to be the same.
+const sameLocationThreshold = 50
+
+// distance returns the great-circle distance between a and b, in meters,
+// using the haversine formula.
+func distance(a, b Location) float64 {
+	lat1, lat2 := radians(a.Lat), radians(b.Lat)
+	dLat := lat2 - lat1
+	dLng := radians(b.Lng - a.Lng)
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(h))
+}
+
+func radians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDistance(t *testing.T) {
+	sf := Location{Lat: 37.7749, Lng: -122.4194}
+
+	if d := distance(sf, sf); d != 0 {
+		t.Errorf("same location: got %f, want 0", d)
+	}
+
+	// About 33m north: under the threshold.
+	near := Location{Lat: sf.Lat + 0.0003, Lng: sf.Lng}
+	if d := distance(sf, near); d >= sameLocationThreshold {
+		t.Errorf("near location: got %f, want < %d", d, sameLocationThreshold)
+	}
+
+	// About 67m north: over the threshold.
+	notNear := Location{Lat: sf.Lat + 0.0006, Lng: sf.Lng}
+	if d := distance(sf, notNear); d < sameLocationThreshold {
+		t.Errorf("location past the threshold: got %f, want >= %d", d, sameLocationThreshold)
+	}
+
+	// One degree of latitude is about 111.2km.
+	north := Location{Lat: sf.Lat + 1, Lng: sf.Lng}
+	if d := distance(sf, north); math.Abs(d-111195) > 1 {
+		t.Errorf("one degree north: got %f, want about 111195", d)
+	}
+
+	// Distance is symmetric.
+	oakland := Location{Lat: 37.8044, Lng: -122.2712}
+	if d1, d2 := distance(sf, oakland), distance(oakland, sf); math.Abs(d1-d2) > 1e-6 {
+		t.Errorf("not symmetric: %f != %f", d1, d2)
+	}
+}
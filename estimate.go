@@ -0,0 +1,218 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/nishanths/lyft-go"
+)
+
+func cmdCost(args []string, flags Flags) {
+	inter := getInternal(flags)
+	lyftClient := newLyftClient(inter)
+
+	start, err := startLocation(flags)
+	if err != nil {
+		log.Fatal(err)
+	}
+	end, err := endLocation(flags, "Enter end location (street address or lat,lng): ")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if end == nil {
+		log.Fatalf("must specify an end location to estimate cost")
+	}
+
+	if flags.template == nil {
+		printRoute(start, end)
+		fmt.Fprintln(os.Stdout)
+	}
+
+	// NOTE: The rideType argument isn't used, because the lyft-go version
+	// we depend on sends the wrong value for it. Estimates for all ride
+	// types are returned instead.
+	var estimates []lyft.CostEstimate
+	var h http.Header
+	err = withRefresh(lyftClient, &inter, func() (err error) {
+		estimates, h, err = lyftClient.CostEstimates(start.Lat, start.Lng, end.Lat, end.Lng, "")
+		return err
+	})
+	if err != nil {
+		log.Fatalf("fetching cost estimates: %s", describeError(err, h, inter.Sandbox))
+	}
+
+	if flags.template != nil {
+		for _, e := range estimates {
+			executeTemplate(flags.template, e)
+		}
+		os.Exit(0)
+	}
+
+	w := standardTabWriter()
+	for _, e := range estimates {
+		if !e.Valid {
+			fmt.Fprintf(w, "%s:\tunavailable\n", e.DisplayName)
+			continue
+		}
+		fmt.Fprintf(w, "%s:\t%s-%s\t%.1f mi\t%s\n", e.DisplayName, formatCents(e.MinimumCost), formatCents(e.MaximumCost), e.Distance, e.Duration)
+	}
+	w.Flush()
+	os.Exit(0)
+}
+
+func cmdETA(args []string, flags Flags) {
+	inter := getInternal(flags)
+	lyftClient := newLyftClient(inter)
+
+	start, err := startLocation(flags)
+	if err != nil {
+		log.Fatal(err)
+	}
+	endLat, endLng := lyft.IgnoreArg, lyft.IgnoreArg
+	if flags.endPlace != "" {
+		end, err := endLocation(flags, "")
+		if err != nil {
+			log.Fatal(err)
+		}
+		endLat, endLng = end.Lat, end.Lng
+	}
+
+	// If there's an end location, the cost estimates are fetched as well,
+	// at the same time, for the ride durations used to compute the
+	// arrival time at the end location.
+	var costs []lyft.CostEstimate
+	var costsErr error
+	var costsH http.Header
+	var wg sync.WaitGroup
+	if flags.endPlace != "" && flags.template == nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			costsErr = withRefresh(lyftClient, &inter, func() (err error) {
+				costs, costsH, err = lyftClient.CostEstimates(start.Lat, start.Lng, endLat, endLng, "")
+				return err
+			})
+		}()
+	}
+
+	// See the note in cmdCost about the rideType argument.
+	var estimates []lyft.ETAEstimate
+	var h http.Header
+	err = withRefresh(lyftClient, &inter, func() (err error) {
+		estimates, h, err = lyftClient.DriverETA(start.Lat, start.Lng, endLat, endLng, "")
+		return err
+	})
+	wg.Wait()
+	if err != nil {
+		log.Fatalf("fetching ETA estimates: %s", describeError(err, h, inter.Sandbox))
+	}
+	if costsErr != nil {
+		log.Fatalf("fetching cost estimates: %s", describeError(costsErr, costsH, inter.Sandbox))
+	}
+
+	if flags.template != nil {
+		for _, e := range estimates {
+			executeTemplate(flags.template, e)
+		}
+		os.Exit(0)
+	}
+
+	durations := rideDurations(costs)
+	now := time.Now()
+	w := standardTabWriter()
+	for _, e := range estimates {
+		if !e.Valid {
+			fmt.Fprintf(w, "%s:\tunavailable\n", e.DisplayName)
+			continue
+		}
+		if t, ok := destinationArrival(e, durations, now); ok {
+			fmt.Fprintf(w, "%s:\t%s\tarrive at %s\n", e.DisplayName, formatETA(e.ETA, now), t.Local().Format("3:04 PM"))
+			continue
+		}
+		fmt.Fprintf(w, "%s:\t%s\n", e.DisplayName, formatETA(e.ETA, now))
+	}
+	w.Flush()
+	os.Exit(0)
+}
+
+// startLocation returns the saved place specified by the -from flag,
+// or prompts for a start location if the flag isn't set.
+func startLocation(flags Flags) (*Location, error) {
+	if flags.startPlace != "" {
+		loc, err := placeByName(flags.startPlace)
+		if err != nil {
+			return nil, err
+		}
+		return &loc, nil
+	}
+	loc, err := parseLocationInput(interactiveInput("Enter start location (street address or lat,lng): "), mapsClient, flags.region)
+	if err != nil {
+		return nil, err
+	}
+	return &loc, nil
+}
+
+// endLocation returns the saved place specified by the -to flag, or
+// prompts for an end location using prompt if the flag isn't set.
+// It returns nil, and a nil error, if the entered end location is empty.
+func endLocation(flags Flags, prompt string) (*Location, error) {
+	if flags.endPlace != "" {
+		loc, err := placeByName(flags.endPlace)
+		if err != nil {
+			return nil, err
+		}
+		return &loc, nil
+	}
+	str := interactiveInput(prompt)
+	if str == "" {
+		return nil, nil
+	}
+	loc, err := parseLocationInput(str, mapsClient, flags.region)
+	if err != nil {
+		return nil, err
+	}
+	return &loc, nil
+}
+
+// rideDurations returns the estimated ride durations by ride type, for
+// the valid cost estimates.
+func rideDurations(costs []lyft.CostEstimate) map[string]time.Duration {
+	durations := make(map[string]time.Duration)
+	for _, c := range costs {
+		if c.Valid {
+			durations[c.RideType] = c.Duration
+		}
+	}
+	return durations
+}
+
+// destinationArrival returns the time, as of now, at which a ride of the
+// ETA estimate's type would arrive at the end location: the driver's ETA
+// plus the ride's duration. It returns false if the duration for the ride
+// type isn't known.
+func destinationArrival(e lyft.ETAEstimate, durations map[string]time.Duration, now time.Time) (time.Time, bool) {
+	d, ok := durations[e.RideType]
+	if !ok {
+		return time.Time{}, false
+	}
+	return arrivalTime(e.ETA+d, now), true
+}
+
+// arrivalTime returns the wall-clock time at which something with the
+// supplied ETA, as of now, is expected to arrive.
+func arrivalTime(eta time.Duration, now time.Time) time.Time {
+	return now.Add(eta)
+}
+
+// formatETA formats the ETA as both a duration and the corresponding
+// local clock time, for example "4m0s, at 3:45 PM".
+func formatETA(eta time.Duration, now time.Time) string {
+	if eta <= 0 {
+		return eta.String()
+	}
+	return fmt.Sprintf("%s, at %s", eta, arrivalTime(eta, now).Local().Format("3:04 PM"))
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nishanths/lyft-go"
+)
+
+func TestStartEndLocation(t *testing.T) {
+	tempHome(t)
+	home := Location{Lat: 37.7749, Lng: -122.4194, Address: "1 Market St"}
+	work := Location{Lat: 37.7849, Lng: -122.4094}
+	if err := writePlaces(map[string]Location{"home": home, "work": work}); err != nil {
+		t.Fatal(err)
+	}
+
+	start, err := startLocation(Flags{startPlace: "home"})
+	if err != nil {
+		t.Fatalf("startLocation: %s", err)
+	}
+	if *start != home {
+		t.Errorf("startLocation: got %+v, want %+v", *start, home)
+	}
+
+	end, err := endLocation(Flags{endPlace: "work"}, "")
+	if err != nil {
+		t.Fatalf("endLocation: %s", err)
+	}
+	if *end != work {
+		t.Errorf("endLocation: got %+v, want %+v", *end, work)
+	}
+
+	const want = `place "x" not found`
+	if _, err := startLocation(Flags{startPlace: "x"}); err == nil || err.Error() != want {
+		t.Errorf("startLocation: got error %v, want %s", err, want)
+	}
+	if _, err := endLocation(Flags{endPlace: "x"}, ""); err == nil || err.Error() != want {
+		t.Errorf("endLocation: got error %v, want %s", err, want)
+	}
+}
+
+func TestArrivalTime(t *testing.T) {
+	now := time.Date(2018, 11, 23, 15, 41, 0, 0, time.Local)
+	if got, want := arrivalTime(4*time.Minute, now), now.Add(4*time.Minute); !got.Equal(want) {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	if got := arrivalTime(0, now); !got.Equal(now) {
+		t.Errorf("zero ETA: got %s, want %s", got, now)
+	}
+}
+
+func TestFormatETA(t *testing.T) {
+	now := time.Date(2018, 11, 23, 15, 41, 0, 0, time.Local)
+	tests := []struct {
+		eta  time.Duration
+		want string
+	}{
+		{4 * time.Minute, "4m0s, at 3:45 PM"},
+		{25 * time.Minute, "25m0s, at 4:06 PM"},
+		{30 * time.Second, "30s, at 3:41 PM"},
+		{0, "0s"},
+		{-time.Minute, "-1m0s"},
+	}
+	for _, tt := range tests {
+		if got := formatETA(tt.eta, now); got != tt.want {
+			t.Errorf("formatETA(%s): got %q, want %q", tt.eta, got, tt.want)
+		}
+	}
+}
+
+func TestDestinationArrival(t *testing.T) {
+	now := time.Date(2018, 11, 23, 15, 41, 0, 0, time.Local)
+	costs := []lyft.CostEstimate{
+		{RideType: lyft.RideTypeLyft, Duration: 15 * time.Minute, Valid: true},
+		{RideType: lyft.RideTypePlus, Duration: 20 * time.Minute, Valid: false},
+	}
+	durations := rideDurations(costs)
+	if len(durations) != 1 || durations[lyft.RideTypeLyft] != 15*time.Minute {
+		t.Errorf("rideDurations: got %v", durations)
+	}
+
+	got, ok := destinationArrival(lyft.ETAEstimate{RideType: lyft.RideTypeLyft, ETA: 4 * time.Minute}, durations, now)
+	if want := now.Add(19 * time.Minute); !ok || !got.Equal(want) {
+		t.Errorf("got (%s, %t), want (%s, true)", got, ok, want)
+	}
+	if got.Format("3:04 PM") != "4:00 PM" {
+		t.Errorf("got %s, want 4:00 PM", got.Format("3:04 PM"))
+	}
+
+	// No valid cost estimate for the ride type.
+	if _, ok := destinationArrival(lyft.ETAEstimate{RideType: lyft.RideTypePlus, ETA: time.Minute}, durations, now); ok {
+		t.Error("ride type without a duration: got ok")
+	}
+}
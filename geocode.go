@@ -1,7 +1,7 @@
 package main
 
 import (
-	"log"
+	"errors"
 	"os"
 	"sync"
 
@@ -10,31 +10,37 @@ import (
 
 const geocodeEnv = "GOOG_GEOCODE_KEY"
 
+// errNoGeocodeKey is returned when a street address needs to be geocoded
+// but the Google Maps Geocode API key isn't set.
+var errNoGeocodeKey = errors.New("set " + geocodeEnv + " to use street addresses, or enter lat,lng; see https://godoc.org/github.com/nishanths/lyft#hdr-Setup")
+
 // geocodeKey returns the Google Maps Geocode API key.
-// If it is not found, it logs a fatal error.
-func geocodeKey() string {
+// If it is not found, the error is errNoGeocodeKey.
+func geocodeKey() (string, error) {
 	key := os.Getenv(geocodeEnv)
 	if key == "" {
-		log.Fatalf("%s must be set to geocode addresses; see https://godoc.org/github.com/nishanths/lyft#hdr-Setup", geocodeEnv)
+		return "", errNoGeocodeKey
 	}
-	return key
+	return key, nil
 }
 
 var (
 	gc     *maps.Client
+	gcErr  error
 	gcInit sync.Once
 )
 
 // mapsClient returns a Google Maps client ready to make
-// geocode requests. It logs a fatal error if the client cannot be created.
-func mapsClient() *maps.Client {
+// geocode requests. It is only called when geocoding is needed, so that
+// lat,lng input works without the API key.
+func mapsClient() (*maps.Client, error) {
 	gcInit.Do(func() {
-		key := geocodeKey()
-		client, err := maps.NewClient(maps.WithAPIKey(key))
+		key, err := geocodeKey()
 		if err != nil {
-			log.Fatalf("making google maps client: %s", err)
+			gcErr = err
+			return
 		}
-		gc = client
+		gc, gcErr = maps.NewClient(maps.WithAPIKey(key))
 	})
-	return gc
+	return gc, gcErr
 }
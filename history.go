@@ -0,0 +1,270 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/nishanths/lyft-go"
+	"github.com/nishanths/lyft-go/auth"
+)
+
+// historyWindow is how far back the history and ride list subcommands
+// ask the API for rides by default. The API doesn't go back much further
+// than this anyway.
+const historyWindow = 30 * 24 * time.Hour
+
+// HistoryEntry is a completed ride saved in the local history file.
+// lyft.RideDetail can't be used directly, since its JSON encoding doesn't
+// round trip.
+type HistoryEntry struct {
+	RideID      string
+	RideStatus  string
+	RideType    string
+	Requested   time.Time
+	Origin      string // Address.
+	Destination string // Address.
+	Distance    float64
+	Duration    time.Duration
+	Price       int
+	Currency    string
+}
+
+func historyEntry(detail lyft.RideDetail) HistoryEntry {
+	origin, destination := detail.Pickup.Address, detail.Dropoff.Address
+	if origin == "" {
+		origin = detail.Origin.Address
+	}
+	if destination == "" {
+		destination = detail.Destination.Address
+	}
+	return HistoryEntry{
+		RideID:      detail.RideID,
+		RideStatus:  detail.RideStatus,
+		RideType:    detail.RideType,
+		Requested:   detail.Requested,
+		Origin:      origin,
+		Destination: destination,
+		Distance:    detail.Distance,
+		Duration:    detail.Duration,
+		Price:       detail.Price.Amount,
+		Currency:    detail.Price.Currency,
+	}
+}
+
+func cmdHistory(args []string, flags Flags) {
+	fs := flag.NewFlagSet("history", flag.ExitOnError)
+	local := fs.Bool("local", false, "")
+	status := fs.String("status", "", "")
+	fs.Usage = usage
+	fs.Parse(args)
+
+	if *status != "" && !validRideStatus(*status) {
+		log.Fatalf("unknown ride status %q", *status)
+	}
+
+	rides := rideHistory(historyWindow, flags)
+	remote := make([]HistoryEntry, len(rides))
+	for i, r := range rides {
+		remote[i] = historyEntry(r)
+	}
+
+	entries := remote
+	if *local {
+		saved, err := readHistory()
+		if err != nil {
+			log.Fatalf("reading history: %s", err)
+		}
+		entries = mergeHistory(saved, remote)
+	}
+	if *status != "" {
+		// The API has no status filter, so filter here.
+		entries = filterHistory(entries, *status)
+	}
+
+	printHistory(entries)
+	os.Exit(0)
+}
+
+func cmdRideList(args []string, flags Flags) {
+	fs := flag.NewFlagSet("list", flag.ExitOnError)
+	since := fs.Duration("since", historyWindow, "")
+	fs.Usage = usage
+	fs.Parse(args)
+
+	rides := rideHistory(*since, flags)
+	if flags.json {
+		out := make([]rideJSON, len(rides))
+		for i, r := range rides {
+			out[i] = newRideJSON(r)
+		}
+		writeJSON(out)
+		os.Exit(0)
+	}
+
+	entries := make([]HistoryEntry, len(rides))
+	for i, r := range rides {
+		entries[i] = historyEntry(r)
+	}
+	printHistory(entries)
+	os.Exit(0)
+}
+
+// rideHistory returns the rides requested in the last since duration,
+// according to the API. If the history preference is set, the completed
+// rides are saved to the local history as well. It logs a fatal error if
+// the rides can't be fetched.
+func rideHistory(since time.Duration, flags Flags) []lyft.RideDetail {
+	inter := getInternal(flags)
+	if err := requireScope(inter, auth.RidesRead); err != nil {
+		log.Fatal(err)
+	}
+	lyftClient := newLyftClient(inter)
+
+	var rides []lyft.RideDetail
+	var h http.Header
+	err := withRefresh(lyftClient, &inter, func() (err error) {
+		rides, h, err = lyftClient.RideHistory(time.Now().Add(-since), time.Time{}, -1)
+		return err
+	})
+	if err != nil {
+		log.Fatalf("fetching ride history: %s", describeError(err, h, inter.Sandbox))
+	}
+
+	if flags.history {
+		var completed []lyft.RideDetail
+		for _, r := range rides {
+			if r.RideStatus == lyft.StatusDroppedOff {
+				completed = append(completed, r)
+			}
+		}
+		if err := recordRides(completed...); err != nil {
+			log.Printf("saving rides to history: %s", err)
+		}
+	}
+	return rides
+}
+
+// printHistory prints the ride ID, requested time, ride type, status,
+// and price of each ride.
+func printHistory(entries []HistoryEntry) {
+	w := standardTabWriter()
+	for _, e := range entries {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+			e.RideID,
+			e.Requested.Local().Format("Jan 2, 2006 3:04 PM"),
+			lyft.RideTypeDisplay(e.RideType),
+			lyft.RideStatusDisplay(e.RideStatus),
+			formatAmount(e.Price, e.Currency),
+		)
+	}
+	w.Flush()
+}
+
+// mergeHistory combines the locally saved rides with the rides from the
+// API, keeping the API's version of rides present in both. The result is
+// sorted by the time the ride was requested, most recent first.
+func mergeHistory(saved map[string]HistoryEntry, remote []HistoryEntry) []HistoryEntry {
+	m := make(map[string]HistoryEntry, len(saved)+len(remote))
+	for id, e := range saved {
+		m[id] = e
+	}
+	for _, e := range remote {
+		m[e.RideID] = e
+	}
+
+	ret := make([]HistoryEntry, 0, len(m))
+	for _, e := range m {
+		ret = append(ret, e)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if !ret[i].Requested.Equal(ret[j].Requested) {
+			return ret[i].Requested.After(ret[j].Requested)
+		}
+		return ret[i].RideID < ret[j].RideID
+	})
+	return ret
+}
+
+// validRideStatus reports whether s is a ride status that can be used
+// with the history subcommand's -status flag.
+func validRideStatus(s string) bool {
+	switch s {
+	case lyft.StatusPending, lyft.StatusAccepted, lyft.StatusArrived, lyft.StatusPickedUp, lyft.StatusDroppedOff, lyft.StatusCanceled:
+		return true
+	}
+	return false
+}
+
+// filterHistory returns the entries with the supplied ride status.
+func filterHistory(entries []HistoryEntry, status string) []HistoryEntry {
+	var ret []HistoryEntry
+	for _, e := range entries {
+		if e.RideStatus == status {
+			ret = append(ret, e)
+		}
+	}
+	return ret
+}
+
+// recordRides saves the rides to the local history file in a single
+// write, replacing any earlier entries for the same rides. Concurrent
+// calls, including from other processes, are serialized using a lock file.
+func recordRides(details ...lyft.RideDetail) error {
+	if len(details) == 0 {
+		return nil
+	}
+	home := HOME()
+	if err := os.MkdirAll(filepath.Join(home, rootDir), permRootDir); err != nil {
+		return err
+	}
+	return withFileLock(filepath.Join(home, rootDir, historyFile), func() error {
+		entries, err := readHistory()
+		if err != nil {
+			return err
+		}
+		for _, d := range details {
+			entries[d.RideID] = historyEntry(d)
+		}
+		return writeStored(historyFile, entries)
+	})
+}
+
+// readHistory returns the locally saved rides keyed by ride ID. The map is
+// empty if no rides have been saved yet.
+func readHistory() (map[string]HistoryEntry, error) {
+	entries := make(map[string]HistoryEntry)
+	if _, err := readStored(historyFile, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// withFileLock calls f while holding an exclusive lock for the file at
+// path. The lock is a separate file, created next to path, that exists for
+// as long as the lock is held.
+func withFileLock(path string, f func() error) error {
+	lock := path + ".lock"
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		lf, err := os.OpenFile(lock, os.O_CREATE|os.O_EXCL|os.O_WRONLY, permFile)
+		if err == nil {
+			lf.Close()
+			break
+		}
+		if !os.IsExist(err) {
+			return err
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out waiting for lock %s; remove it if no other lyft process is running", lock)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	defer os.Remove(lock)
+	return f()
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nishanths/lyft-go"
+)
+
+func TestMergeHistory(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2018, 11, d, 12, 0, 0, 0, time.UTC) }
+	saved := map[string]HistoryEntry{
+		"old":    {RideID: "old", Requested: day(1), Price: 1000},
+		"both":   {RideID: "both", Requested: day(3), Price: 1500},
+		"same-a": {RideID: "same-a", Requested: day(2)},
+	}
+	remote := []HistoryEntry{
+		{RideID: "both", Requested: day(3), Price: 1750}, // updated by the API
+		{RideID: "new", Requested: day(5)},
+		{RideID: "same-b", Requested: day(2)},
+	}
+
+	got := mergeHistory(saved, remote)
+	wantIDs := []string{"new", "both", "same-a", "same-b", "old"}
+	if len(got) != len(wantIDs) {
+		t.Fatalf("got %d entries, want %d: %+v", len(got), len(wantIDs), got)
+	}
+	for i, id := range wantIDs {
+		if got[i].RideID != id {
+			t.Errorf("entry %d: got %s, want %s", i, got[i].RideID, id)
+		}
+	}
+	if got[1].Price != 1750 {
+		t.Errorf("ride in both: got price %d, want the API's 1750", got[1].Price)
+	}
+}
+
+func TestRecordRides(t *testing.T) {
+	home := tempHome(t)
+	if err := recordRides(lyft.RideDetail{RideID: "1", RideStatus: lyft.StatusDroppedOff}); err != nil {
+		t.Fatal(err)
+	}
+	if err := recordRides(
+		lyft.RideDetail{RideID: "1", RideStatus: lyft.StatusDroppedOff, Price: lyft.Price{Amount: 1200}},
+		lyft.RideDetail{RideID: "2", RideStatus: lyft.StatusDroppedOff},
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := readHistory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2: %+v", len(entries), entries)
+	}
+	if entries["1"].Price != 1200 {
+		t.Errorf("ride 1: got price %d, want the later 1200", entries["1"].Price)
+	}
+
+	// Only the history file is left behind: no lock or temporary files.
+	infos, err := ioutil.ReadDir(filepath.Join(home, rootDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 1 || infos[0].Name() != historyFile {
+		var names []string
+		for _, fi := range infos {
+			names = append(names, fi.Name())
+		}
+		t.Errorf("got files %v, want only %s", names, historyFile)
+	}
+}
+
+func TestValidRideStatus(t *testing.T) {
+	for _, s := range []string{lyft.StatusPending, lyft.StatusAccepted, lyft.StatusArrived, lyft.StatusPickedUp, lyft.StatusDroppedOff, lyft.StatusCanceled} {
+		if !validRideStatus(s) {
+			t.Errorf("%s: got invalid", s)
+		}
+	}
+	for _, s := range []string{"", "droppedoff", "dropped_off", lyft.StatusUnknown} {
+		if validRideStatus(s) {
+			t.Errorf("%q: got valid", s)
+		}
+	}
+}
+
+func TestFilterHistory(t *testing.T) {
+	entries := []HistoryEntry{
+		{RideID: "1", RideStatus: lyft.StatusDroppedOff},
+		{RideID: "2", RideStatus: lyft.StatusCanceled},
+		{RideID: "3", RideStatus: lyft.StatusDroppedOff},
+	}
+	got := filterHistory(entries, lyft.StatusDroppedOff)
+	if len(got) != 2 || got[0].RideID != "1" || got[1].RideID != "3" {
+		t.Errorf("droppedOff: got %+v", got)
+	}
+	if got := filterHistory(entries, lyft.StatusPending); len(got) != 0 {
+		t.Errorf("pending: got %+v, want none", got)
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/nishanths/lyft-go"
+	"github.com/nishanths/lyft-go/auth"
+)
+
+func cmdRideICS(args []string, flags Flags) {
+	if len(args) == 0 {
+		log.Fatalf("must specify a <ride-id> to export")
+	}
+
+	inter := getInternal(flags)
+	if err := requireScope(inter, auth.RidesRead); err != nil {
+		log.Fatal(err)
+	}
+	lyftClient := newLyftClient(inter)
+
+	var detail lyft.RideDetail
+	var h http.Header
+	err := withRefresh(lyftClient, &inter, func() (err error) {
+		detail, h, err = lyftClient.RideDetail(args[0])
+		return err
+	})
+	if err != nil {
+		log.Fatalf("fetching ride details: %s", describeError(err, h, inter.Sandbox))
+	}
+
+	fmt.Fprint(os.Stdout, rideICS(detail, time.Now()))
+	os.Exit(0)
+}
+
+// rideICS renders the ride as an iCalendar (RFC 5545) object containing
+// a single VEVENT. The event starts at the pickup time, or the requested
+// time if the ride hasn't been picked up, and ends at the dropoff time,
+// or after the ride's duration if the ride hasn't been dropped off.
+// stamp is used for the DTSTAMP property.
+func rideICS(detail lyft.RideDetail, stamp time.Time) string {
+	start := detail.Pickup.Time
+	if start.IsZero() {
+		start = detail.Requested
+	}
+	end := detail.Dropoff.Time
+	if end.IsZero() {
+		end = start.Add(detail.Duration)
+	}
+
+	from, to := detail.Pickup.Address, detail.Dropoff.Address
+	if from == "" {
+		from = detail.Origin.Address
+	}
+	if to == "" {
+		to = detail.Destination.Address
+	}
+	description := fmt.Sprintf("Ride ID: %s\nFrom: %s\nTo: %s", detail.RideID, from, to)
+	if detail.RouteURL != "" {
+		description += "\nRoute: " + detail.RouteURL
+	}
+
+	lines := []string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//nishanths//lyft//EN",
+		"BEGIN:VEVENT",
+		"UID:" + detail.RideID + "@lyft.com",
+		"DTSTAMP:" + icsTime(stamp),
+		"DTSTART:" + icsTime(start),
+		"DTEND:" + icsTime(end),
+		"SUMMARY:" + icsText(lyft.RideTypeDisplay(detail.RideType)+" ride"),
+		"LOCATION:" + icsText(from),
+		"DESCRIPTION:" + icsText(description),
+		"END:VEVENT",
+		"END:VCALENDAR",
+	}
+
+	var b strings.Builder
+	for _, l := range lines {
+		b.WriteString(icsFold(l))
+		b.WriteString("\r\n")
+	}
+	return b.String()
+}
+
+// icsTime formats t as an iCalendar UTC date-time.
+func icsTime(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
+
+// icsText escapes s for use as an iCalendar TEXT value.
+func icsText(s string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\n", `\n`,
+	).Replace(s)
+}
+
+// icsFold folds the content line l so that no line is longer than 75
+// octets, as required by RFC 5545. Multi-byte characters are not split.
+func icsFold(l string) string {
+	const max = 75
+	var b strings.Builder
+	n := 0
+	for _, r := range l {
+		size := len(string(r))
+		if n+size > max {
+			b.WriteString("\r\n ")
+			n = 1 // the leading space
+		}
+		b.WriteRune(r)
+		n += size
+	}
+	return b.String()
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/nishanths/lyft-go"
+)
+
+func TestRideICS(t *testing.T) {
+	detail := lyft.RideDetail{
+		RideID:   "123",
+		RideType: lyft.RideTypeLyft,
+		Pickup:   lyft.RideLocation{Address: "1 Market St, San Francisco", Time: time.Date(2018, 11, 23, 22, 12, 0, 0, time.UTC)},
+		Dropoff:  lyft.RideLocation{Address: "500 Castro St", Time: time.Date(2018, 11, 23, 22, 40, 0, 0, time.UTC)},
+	}
+	stamp := time.Date(2018, 11, 24, 1, 2, 3, 0, time.UTC)
+	out := rideICS(detail, stamp)
+
+	if !strings.HasSuffix(out, "\r\n") {
+		t.Error("output doesn't end with CRLF")
+	}
+	lines := strings.Split(strings.TrimSuffix(out, "\r\n"), "\r\n")
+	for _, l := range lines {
+		if strings.ContainsAny(l, "\r\n") {
+			t.Errorf("line %q contains a bare CR or LF", l)
+		}
+	}
+
+	// The event is nested within the calendar.
+	want := []string{"BEGIN:VCALENDAR", "BEGIN:VEVENT", "END:VEVENT", "END:VCALENDAR"}
+	var got []string
+	for _, l := range lines {
+		if strings.HasPrefix(l, "BEGIN:") || strings.HasPrefix(l, "END:") {
+			got = append(got, l)
+		}
+	}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("got components %v, want %v", got, want)
+	}
+
+	for _, prop := range []string{
+		"UID:123@lyft.com",
+		"DTSTAMP:20181124T010203Z",
+		"DTSTART:20181123T221200Z",
+		"DTEND:20181123T224000Z",
+		"SUMMARY:Lyft ride",
+		`LOCATION:1 Market St\, San Francisco`,
+	} {
+		found := false
+		for _, l := range lines {
+			if l == prop {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("missing line %q in:\n%s", prop, out)
+		}
+	}
+}
+
+func TestICSText(t *testing.T) {
+	got := icsText("a\\b;c,d\ne")
+	want := `a\\b\;c\,d\ne`
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestICSFold(t *testing.T) {
+	short := strings.Repeat("a", 75)
+	if got := icsFold(short); got != short {
+		t.Errorf("75 octets: got %q, want unfolded", got)
+	}
+
+	long := "DESCRIPTION:" + strings.Repeat("abc", 40)
+	checkFolded(t, long, icsFold(long))
+
+	// Multi-byte runes aren't split across lines.
+	multi := "SUMMARY:" + strings.Repeat("é☕", 30)
+	checkFolded(t, multi, icsFold(multi))
+}
+
+func checkFolded(t *testing.T, orig, folded string) {
+	t.Helper()
+	lines := strings.Split(folded, "\r\n")
+	if len(lines) < 2 {
+		t.Errorf("%q wasn't folded", orig)
+	}
+	var unfolded strings.Builder
+	for i, l := range lines {
+		if len(l) > 75 {
+			t.Errorf("line %d is %d octets: %q", i, len(l), l)
+		}
+		if !utf8.ValidString(l) {
+			t.Errorf("line %d splits a multi-byte character: %q", i, l)
+		}
+		if i > 0 {
+			if !strings.HasPrefix(l, " ") {
+				t.Errorf("continuation line %d doesn't start with a space: %q", i, l)
+			}
+			l = l[1:]
+		}
+		unfolded.WriteString(l)
+	}
+	if unfolded.String() != orig {
+		t.Errorf("unfolded %q, want %q", unfolded.String(), orig)
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"sync"
 	"time"
 
 	"github.com/nishanths/lyft-go"
@@ -19,7 +20,8 @@ import (
 
 type Config struct {
 	ClientID     string
-	ClientSecret string
+	ClientSecret string // Sandboxed if Sandbox is true.
+	Sandbox      bool
 }
 
 type Internal struct {
@@ -27,14 +29,31 @@ type Internal struct {
 	ClientSecret string
 	AccessToken  string
 	RefreshToken string
-	// TODO: we could save an extra trip by saving the expiry as well.
+	Scopes       []string // Granted scopes. Empty in files written by older versions.
+	Sandbox      bool     // Whether the tokens are for Lyft's sandbox.
+
+	// AccessTokenExpiry is when the access token should be considered
+	// expired, so that it is refreshed before making a request that would
+	// fail. Zero if unknown.
+	AccessTokenExpiry time.Time
+
+	noPersist bool   // If true, the tokens aren't written to the internal file.
+	baseURL   string // Base URL of the API; the real API if empty.
+}
+
+// apiBaseURL returns the base URL of the API that i's tokens are for.
+func (i Internal) apiBaseURL() string {
+	if i.baseURL == "" {
+		return lyft.BaseURL
+	}
+	return i.baseURL
 }
 
 func (i Internal) matches(c Config) bool {
 	return i.ClientID == c.ClientID && i.ClientSecret == c.ClientSecret
 }
 
-func readConfig() (c Config, err error) {
+func readConfig(sandbox bool) (c Config, err error) {
 	i := os.Getenv("LYFT_CLIENT_ID")
 	if i == "" {
 		return Config{}, errors.New("LYFT_CLIENT_ID must be set; see https://godoc.org/github.com/nishanths/lyft#hdr-Setup")
@@ -43,37 +62,108 @@ func readConfig() (c Config, err error) {
 	if s == "" {
 		return Config{}, errors.New("LYFT_CLIENT_SECRET must be set; see https://godoc.org/github.com/nishanths/lyft#hdr-Setup")
 	}
+	if sandbox {
+		s = auth.SandboxSecret(s)
+	}
 	return Config{
 		ClientID:     i,
 		ClientSecret: s,
+		Sandbox:      sandbox,
 	}, nil
 }
 
-func getInternal() Internal {
-	c, err := readConfig()
+// internalFileName returns the name of the file, in the program's data
+// directory, that stores the tokens. Sandbox tokens are stored separately,
+// so that they aren't confused with production tokens.
+func internalFileName(sandbox bool) string {
+	if sandbox {
+		return sandboxInternalFile
+	}
+	return internalFile
+}
+
+// internalPath returns the path of the file that stores the tokens.
+func internalPath(sandbox bool) string {
+	return filepath.Join(HOME(), rootDir, internalFileName(sandbox))
+}
+
+// modeName returns the name of the API mode for use in messages.
+func modeName(sandbox bool) string {
+	if sandbox {
+		return "sandbox"
+	}
+	return "production"
+}
+
+func getInternal(flags Flags) Internal {
+	if flags.mock {
+		// No credentials or authorization needed.
+		return Internal{AccessToken: "mock", noPersist: true, baseURL: startMockServer()}
+	}
+
+	c, err := readConfig(flags.sandbox)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	return ensureInternal(c)
+	inter := ensureInternal(c, flags.noPersist)
+	if inter.expired(time.Now()) {
+		inter = refreshAndWriteToken(inter)
+	}
+	return inter
+}
+
+// tokenExpiryMargin is subtracted from an access token's lifetime, so that
+// a token that is about to expire is refreshed early.
+const tokenExpiryMargin = time.Minute
+
+// tokenExpiry returns the AccessTokenExpiry for an access token issued at
+// now with lifetime d, or the zero time if d is zero (unknown).
+func tokenExpiry(now time.Time, d time.Duration) time.Time {
+	if d == 0 {
+		return time.Time{}
+	}
+	return now.Add(d - tokenExpiryMargin)
 }
 
-func ensureInternal(c Config) Internal {
+// expired reports whether the access token should be refreshed before
+// using it at now. It returns false if the expiry isn't known.
+func (i Internal) expired(now time.Time) bool {
+	return !i.AccessTokenExpiry.IsZero() && !now.Before(i.AccessTokenExpiry)
+}
+
+// ensureInternal returns the tokens in the internal file, or obtains new
+// ones if the file doesn't exist or is out of sync with c. If noPersist is
+// true, the internal file is only read: new tokens aren't written to it,
+// and an out of sync file is left as is.
+func ensureInternal(c Config, noPersist bool) Internal {
 	var inter Internal
-	internalFilepath := filepath.Join(HOME(), rootDir, internalFile)
+	internalFilepath := internalPath(c.Sandbox)
 	b, fileErr := ioutil.ReadFile(internalFilepath)
 
 	if fileErr == nil {
 		// Good. It's there.
-		if err := json.Unmarshal(b, &inter); err != nil {
-			log.Fatalf("unmarshaling internal config: %s", err)
+		var err error
+		if inter, err = decodeInternal(b, c); err != nil {
+			log.Fatal(err)
 		}
 
 		// Still in sync, hopefully?
 		if inter.matches(c) {
 			// It is still in sync. We're done.
+			inter.noPersist = noPersist
 			return inter
 		}
+	}
+
+	if noPersist {
+		// Use new tokens for this run only.
+		inter = newInternal(c)
+		inter.noPersist = true
+		return inter
+	}
+
+	if fileErr == nil {
 		// Out of sync. Let's revoke the tokens here, before we
 		// end up razing the file in the upcoming steps.
 		revokeToken(c.ClientID, c.ClientSecret, inter.AccessToken)
@@ -89,44 +179,116 @@ func ensureInternal(c Config) Internal {
 	}
 
 	// Try to obtain the access and refresh tokens.
+	inter = newInternal(c)
+	if err := writeStored(internalFileName(c.Sandbox), inter); err != nil {
+		revokeToken(c.ClientID, c.ClientSecret, inter.AccessToken)
+		log.Fatalf("writing internal file: %s", err)
+	}
+	return inter
+}
+
+// decodeInternal decodes the contents of the internal file for c's mode.
+// It returns an error if the tokens are for the other mode: they are never
+// used, since the API would reject them anyway, but less clearly.
+func decodeInternal(b []byte, c Config) (Internal, error) {
+	var inter Internal
+	if err := json.Unmarshal(b, &inter); err != nil {
+		return Internal{}, fmt.Errorf("unmarshaling internal config: %s", err)
+	}
+	if inter.Sandbox != c.Sandbox {
+		return Internal{}, fmt.Errorf("%s has %s tokens, but %s mode is in use; remove the file to authorize again", internalPath(c.Sandbox), modeName(inter.Sandbox), modeName(c.Sandbox))
+	}
+	return inter, nil
+}
+
+// newLyftClient returns a client for the API that inter's tokens are for.
+func newLyftClient(inter Internal) *lyft.Client {
+	c := lyft.NewClient(inter.AccessToken)
+	c.BaseURL = inter.baseURL
+	return c
+}
+
+// newInternal obtains new access and refresh tokens by requesting
+// authorization from the user.
+func newInternal(c Config) Internal {
 	code := obtainAuthorizationCode(c)
 	t, _, err := threeleg.GenerateToken(http.DefaultClient, lyft.BaseURL, c.ClientID, c.ClientSecret, code)
 	if err != nil {
 		log.Fatalf("generating access token: %s", err)
 	}
+	return Internal{
+		ClientID:          c.ClientID,
+		ClientSecret:      c.ClientSecret,
+		AccessToken:       t.AccessToken,
+		RefreshToken:      t.RefreshToken,
+		Scopes:            t.Scopes,
+		Sandbox:           c.Sandbox,
+		AccessTokenExpiry: tokenExpiry(time.Now(), t.Expires),
+	}
+}
 
-	inter = Internal{
-		ClientID:     c.ClientID,
-		ClientSecret: c.ClientSecret,
-		AccessToken:  t.AccessToken,
-		RefreshToken: t.RefreshToken,
+// requireScope returns an error if the scope wasn't granted, so that a
+// request that would fail with insufficient_scope isn't made. The check is
+// skipped if the granted scopes aren't known.
+func requireScope(inter Internal, scope string) error {
+	if len(inter.Scopes) == 0 {
+		return nil
 	}
-	data, err := json.Marshal(inter)
+	for _, s := range inter.Scopes {
+		if s == scope {
+			return nil
+		}
+	}
+	return fmt.Errorf("re-authorize to grant %s: remove %s and run the command again", scope, internalPath(inter.Sandbox))
+}
+
+// refreshAndWriteToken refreshes the access token and returns inter
+// updated with the refreshed token, its expiry, and its scopes. The
+// updated Internal is written to the internal file unless noPersist is set.
+func refreshAndWriteToken(inter Internal) Internal {
+	refreshed, _, err := threeleg.RefreshToken(http.DefaultClient, inter.apiBaseURL(), inter.ClientID, inter.ClientSecret, inter.RefreshToken)
 	if err != nil {
-		revokeToken(c.ClientID, c.ClientSecret, inter.AccessToken)
-		log.Fatalf("marshaling internal config: %s", err)
+		log.Fatalf("refreshing expired token: %s", err)
 	}
-	if err := os.MkdirAll(filepath.Join(HOME(), rootDir), permRootDir); err != nil {
-		revokeToken(c.ClientID, c.ClientSecret, inter.AccessToken)
-		log.Fatalf("making .%s directory: %s", rootDir, err)
+	// Save the refreshed token, not the expired one. The refresh token
+	// itself isn't rotated by the refresh response.
+	inter.AccessToken = refreshed.AccessToken
+	inter.AccessTokenExpiry = tokenExpiry(time.Now(), refreshed.Expires)
+	if len(refreshed.Scopes) != 0 {
+		inter.Scopes = refreshed.Scopes
 	}
-	if err := ioutil.WriteFile(internalFilepath, data, permFile); err != nil {
-		revokeToken(c.ClientID, c.ClientSecret, inter.AccessToken)
-		log.Fatalf("writing internal file: %s", err)
+	if inter.noPersist {
+		return inter
+	}
+	if err := writeStored(internalFileName(inter.Sandbox), inter); err != nil {
+		// Keep going; we have the access token in-memory for now.
+		log.Printf("writing refreshed token to internal file: %s", err)
 	}
 	return inter
 }
 
-func refreshAndWriteToken(inter Internal) (accessToken string) {
-	refreshed, _, err := threeleg.RefreshToken(http.DefaultClient, lyft.BaseURL, inter.ClientID, inter.ClientSecret, inter.RefreshToken)
-	if err != nil {
-		log.Fatalf("refreshing expired token: %s", err)
-	}
-	data, err := json.Marshal(inter)
-	if err == nil {
-		ioutil.WriteFile(filepath.Join(HOME(), rootDir, internalFile), data, permFile) // ignore error, we have the access token in-memory for now
+// refreshMu serializes token refreshes by concurrent withRefresh calls.
+var refreshMu sync.Mutex
+
+// withRefresh calls f. If f fails because the access token expired, the
+// token is refreshed, inter and the client's access token are updated, and
+// f is called once more. It is safe to call concurrently with the same
+// client and inter: the token is refreshed, and the internal file written,
+// only once.
+func withRefresh(lyftClient *lyft.Client, inter *Internal, f func() error) error {
+	token := lyftClient.AccessToken()
+	err := f()
+	if lyft.IsTokenExpired(err) {
+		refreshMu.Lock()
+		// Another call may have refreshed the token while f was running.
+		if lyftClient.AccessToken() == token {
+			*inter = refreshAndWriteToken(*inter)
+			lyftClient.SetAccessToken(inter.AccessToken)
+		}
+		refreshMu.Unlock()
+		err = f()
 	}
-	return refreshed.AccessToken
+	return err
 }
 
 func revokeToken(clientID, clientSecret, a string) (http.Header, error) {
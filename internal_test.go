@@ -0,0 +1,403 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nishanths/lyft-go"
+	"github.com/nishanths/lyft-go/auth"
+)
+
+// writeInternal writes inter to the internal file for its mode.
+func writeInternal(t *testing.T, inter Internal) []byte {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(internalPath(inter.Sandbox)), permRootDir); err != nil {
+		t.Fatal(err)
+	}
+	data, err := marshalStable(inter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(internalPath(inter.Sandbox), data, permFile); err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestEnsureInternalNoPersist(t *testing.T) {
+	home := tempHome(t)
+	c := Config{ClientID: "id", ClientSecret: "secret"}
+	saved := Internal{
+		ClientID:          c.ClientID,
+		ClientSecret:      c.ClientSecret,
+		AccessToken:       "access",
+		RefreshToken:      "refresh",
+		AccessTokenExpiry: time.Now().Add(time.Hour).Round(0),
+	}
+	data := writeInternal(t, saved)
+
+	inter := ensureInternal(c, true)
+	if inter.AccessToken != "access" || inter.RefreshToken != "refresh" {
+		t.Errorf("got tokens %q, %q; want the saved tokens", inter.AccessToken, inter.RefreshToken)
+	}
+	if !inter.noPersist {
+		t.Error("noPersist not set")
+	}
+
+	// The internal file is unchanged, and nothing else is written.
+	got, err := ioutil.ReadFile(internalPath(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("internal file changed:\n%s\nwant:\n%s", got, data)
+	}
+	infos, err := ioutil.ReadDir(filepath.Join(home, rootDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 1 {
+		var names []string
+		for _, fi := range infos {
+			names = append(names, fi.Name())
+		}
+		t.Errorf("got files %v, want only %s", names, internalFile)
+	}
+}
+
+func TestRequireScope(t *testing.T) {
+	tempHome(t)
+	granted := Internal{Scopes: []string{auth.Public, auth.RidesRead, auth.Offline}}
+	if err := requireScope(granted, auth.RidesRead); err != nil {
+		t.Errorf("granted scope: %s", err)
+	}
+
+	err := requireScope(granted, auth.RidesRequest)
+	if err == nil {
+		t.Fatal("missing scope: expected error")
+	}
+	if !strings.Contains(err.Error(), auth.RidesRequest) || !strings.Contains(err.Error(), internalPath(false)) {
+		t.Errorf("missing scope: error %q doesn't name the scope and the internal file", err)
+	}
+
+	// Unknown scopes, such as in files written by older versions, aren't checked.
+	if err := requireScope(Internal{}, auth.RidesRequest); err != nil {
+		t.Errorf("unknown scopes: %s", err)
+	}
+}
+
+func TestSandboxIsolation(t *testing.T) {
+	tempHome(t)
+	if internalPath(true) == internalPath(false) {
+		t.Fatalf("sandbox and production tokens use the same file %s", internalPath(true))
+	}
+
+	setenv(t, "LYFT_CLIENT_ID", "id")
+	setenv(t, "LYFT_CLIENT_SECRET", "secret")
+	prod, err := readConfig(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prod.ClientSecret != "secret" || prod.Sandbox {
+		t.Errorf("production config: got %+v", prod)
+	}
+	sandbox, err := readConfig(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sandbox.ClientSecret != auth.SandboxSecret("secret") || !sandbox.Sandbox {
+		t.Errorf("sandbox config: got %+v", sandbox)
+	}
+
+	// Each mode reads its own tokens.
+	writeInternal(t, Internal{ClientID: "id", ClientSecret: prod.ClientSecret, AccessToken: "prod"})
+	writeInternal(t, Internal{ClientID: "id", ClientSecret: sandbox.ClientSecret, AccessToken: "sandbox", Sandbox: true})
+	if got := ensureInternal(prod, true).AccessToken; got != "prod" {
+		t.Errorf("production: got access token %q", got)
+	}
+	if got := ensureInternal(sandbox, true).AccessToken; got != "sandbox" {
+		t.Errorf("sandbox: got access token %q", got)
+	}
+}
+
+func TestEnsureInternalWrongMode(t *testing.T) {
+	tempHome(t)
+	c := Config{ClientID: "id", ClientSecret: "secret"}
+
+	// Sandbox tokens in the production file.
+	data, err := marshalStable(Internal{ClientID: "id", ClientSecret: "secret", AccessToken: "sandbox", Sandbox: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(internalPath(false)), permRootDir); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(internalPath(false), data, permFile); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ioutil.ReadFile(internalPath(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = decodeInternal(b, c)
+	if err == nil {
+		t.Fatal("expected error for sandbox tokens in production mode")
+	}
+	want := internalPath(false) + " has sandbox tokens, but production mode is in use; remove the file to authorize again"
+	if err.Error() != want {
+		t.Errorf("got error %q, want %q", err, want)
+	}
+
+	// Tokens for the mode in use are decoded.
+	data = writeInternal(t, Internal{ClientID: "id", ClientSecret: "secret", AccessToken: "prod"})
+	inter, err := decodeInternal(data, c)
+	if err != nil || inter.AccessToken != "prod" {
+		t.Errorf("production tokens: got (%q, %v)", inter.AccessToken, err)
+	}
+}
+
+func TestWithRefresh(t *testing.T) {
+	tempHome(t)
+	inter := Internal{
+		ClientID:     "id",
+		ClientSecret: "secret",
+		AccessToken:  "expired",
+		RefreshToken: "refresh",
+		noPersist:    true,
+		baseURL:      tokenServer(t),
+	}
+	c := newLyftClient(inter)
+	var tokens []string
+	err := withRefresh(c, &inter, func() error {
+		tokens = append(tokens, c.AccessToken())
+		if len(tokens) == 1 {
+			return &lyft.StatusError{StatusCode: 401}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tokens) != 2 || tokens[0] != "expired" || tokens[1] != "refreshed" {
+		t.Errorf("f called with access tokens %q, want [expired refreshed]", tokens)
+	}
+
+	// The caller's Internal has the refreshed token, expiry, and scopes.
+	if inter.AccessToken != "refreshed" || inter.RefreshToken != "refresh" {
+		t.Errorf("got tokens %q, %q; want the refreshed access token", inter.AccessToken, inter.RefreshToken)
+	}
+	if inter.AccessTokenExpiry.IsZero() || len(inter.Scopes) != 4 {
+		t.Errorf("got expiry %s and scopes %q, want the refreshed ones", inter.AccessTokenExpiry, inter.Scopes)
+	}
+}
+
+func TestWithRefreshWritesToken(t *testing.T) {
+	tempHome(t)
+	inter := Internal{
+		ClientID:     "id",
+		ClientSecret: "secret",
+		AccessToken:  "expired",
+		RefreshToken: "refresh",
+		baseURL:      tokenServer(t),
+	}
+	writeInternal(t, inter)
+
+	c := newLyftClient(inter)
+	calls := 0
+	err := withRefresh(c, &inter, func() error {
+		calls++
+		if calls == 1 {
+			return &lyft.StatusError{StatusCode: 401}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.AccessToken() != "refreshed" {
+		t.Errorf("client has access token %q, want %q", c.AccessToken(), "refreshed")
+	}
+
+	// The internal file is rewritten with the refreshed token.
+	b, err := ioutil.ReadFile(internalPath(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved Internal
+	if err := json.Unmarshal(b, &saved); err != nil {
+		t.Fatal(err)
+	}
+	if saved.AccessToken != "refreshed" || saved.RefreshToken != "refresh" || !saved.AccessTokenExpiry.Equal(inter.AccessTokenExpiry) {
+		t.Errorf("saved %+v", saved)
+	}
+}
+
+func TestWithRefreshAlreadyRefreshed(t *testing.T) {
+	// If another call refreshed the token while f was running, the token
+	// isn't refreshed again; f is just retried with the new token.
+	c := lyft.NewClient("old")
+	expired := &lyft.StatusError{StatusCode: 401}
+	calls := 0
+	err := withRefresh(c, &Internal{}, func() error {
+		calls++
+		if calls == 1 {
+			c.SetAccessToken("new")
+			return expired
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 2 {
+		t.Errorf("got %d calls, want 2", calls)
+	}
+	if c.AccessToken() != "new" {
+		t.Errorf("got access token %q, want %q", c.AccessToken(), "new")
+	}
+}
+
+func TestTokenExpiry(t *testing.T) {
+	now := time.Date(2018, 11, 23, 15, 0, 0, 0, time.UTC)
+	if got := tokenExpiry(now, 0); !got.IsZero() {
+		t.Errorf("unknown lifetime: got %s, want the zero time", got)
+	}
+	if got, want := tokenExpiry(now, time.Hour), now.Add(time.Hour-tokenExpiryMargin); !got.Equal(want) {
+		t.Errorf("got %s, want %s", got, want)
+	}
+
+	inter := Internal{AccessTokenExpiry: tokenExpiry(now, time.Hour)}
+	expiry := inter.AccessTokenExpiry
+	tests := []struct {
+		now  time.Time
+		want bool
+	}{
+		{now, false},
+		{expiry.Add(-time.Nanosecond), false},
+		{expiry, true},
+		{now.Add(time.Hour), true},
+	}
+	for _, tt := range tests {
+		if got := inter.expired(tt.now); got != tt.want {
+			t.Errorf("expired at issued+%s: got %t, want %t", tt.now.Sub(now), got, tt.want)
+		}
+	}
+
+	// An unknown expiry is never considered expired.
+	if (Internal{}).expired(now.Add(1000 * time.Hour)) {
+		t.Error("unknown expiry: got expired")
+	}
+}
+
+// tokenServer starts a server that responds to token refresh requests
+// with the access token "refreshed".
+func tokenServer(t *testing.T) string {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/oauth/token" || r.Method != "POST" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"access_token": "refreshed", "token_type": "bearer", "expires_in": 3600, "scope": "public rides.read offline rides.request"}`)
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+func TestRefreshAndWriteToken(t *testing.T) {
+	tempHome(t)
+	inter := Internal{
+		ClientID:     "id",
+		ClientSecret: "secret",
+		AccessToken:  "expired",
+		RefreshToken: "refresh",
+		Scopes:       []string{auth.Public},
+		baseURL:      tokenServer(t),
+	}
+	writeInternal(t, inter)
+
+	before := time.Now()
+	got := refreshAndWriteToken(inter)
+	if got.AccessToken != "refreshed" || got.RefreshToken != "refresh" || len(got.Scopes) != 4 {
+		t.Errorf("got %+v", got)
+	}
+	if got.AccessTokenExpiry.Before(before.Add(time.Hour - tokenExpiryMargin)) {
+		t.Errorf("got expiry %s, want about an hour from now", got.AccessTokenExpiry)
+	}
+
+	// The refreshed token is saved.
+	b, err := ioutil.ReadFile(internalPath(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved Internal
+	if err := json.Unmarshal(b, &saved); err != nil {
+		t.Fatal(err)
+	}
+	if saved.AccessToken != "refreshed" || saved.RefreshToken != "refresh" || !saved.AccessTokenExpiry.Equal(got.AccessTokenExpiry) || len(saved.Scopes) != 4 {
+		t.Errorf("saved %+v", saved)
+	}
+	// It's written through a temporary file that is renamed into place.
+	infos, err := ioutil.ReadDir(filepath.Dir(internalPath(false)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 1 || infos[0].Name() != internalFile || infos[0].Mode().Perm() != permFile {
+		t.Errorf("got %d files in the data directory; want only %s with mode %s", len(infos), internalFile, os.FileMode(permFile))
+	}
+}
+
+func TestRefreshAndWriteTokenWriteError(t *testing.T) {
+	home := tempHome(t)
+	// The data directory can't be created, so the internal file can't be written.
+	if err := ioutil.WriteFile(filepath.Join(home, rootDir), nil, permFile); err != nil {
+		t.Fatal(err)
+	}
+	var logged bytes.Buffer
+	log.SetOutput(&logged)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	inter := Internal{
+		ClientID:     "id",
+		ClientSecret: "secret",
+		AccessToken:  "expired",
+		RefreshToken: "refresh",
+		baseURL:      tokenServer(t),
+	}
+	if got := refreshAndWriteToken(inter); got.AccessToken != "refreshed" {
+		t.Errorf("got access token %q, want %q", got.AccessToken, "refreshed")
+	}
+	if !strings.Contains(logged.String(), "writing refreshed token") {
+		t.Errorf("write error not logged; got %q", logged.String())
+	}
+}
+
+func TestRefreshAndWriteTokenNoPersist(t *testing.T) {
+	home := tempHome(t)
+	inter := Internal{
+		ClientID:     "id",
+		ClientSecret: "secret",
+		AccessToken:  "expired",
+		RefreshToken: "refresh",
+		noPersist:    true,
+		baseURL:      tokenServer(t),
+	}
+	if got := refreshAndWriteToken(inter); got.AccessToken != "refreshed" {
+		t.Errorf("got access token %q, want %q", got.AccessToken, "refreshed")
+	}
+	if _, err := os.Stat(filepath.Join(home, rootDir)); !os.IsNotExist(err) {
+		t.Errorf("data directory written with -no-persist: %v", err)
+	}
+}
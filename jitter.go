@@ -0,0 +1,29 @@
+package main
+
+import (
+	"math/rand"
+	"time"
+)
+
+// jitter randomly varies poll intervals, so that many instances of the
+// program polling on the same schedule don't end up making requests in
+// lockstep.
+type jitter struct {
+	fraction float64        // Maximum variation, as a fraction of the interval.
+	rand     func() float64 // Returns a number in [0.0, 1.0).
+}
+
+// watchJitter is the jitter applied to the ride status watch intervals.
+var watchJitter = jitter{
+	fraction: 0.2,
+	rand:     rand.New(rand.NewSource(time.Now().UnixNano())).Float64,
+}
+
+// apply returns d varied by up to j.fraction of d in either direction.
+func (j jitter) apply(d time.Duration) time.Duration {
+	if j.fraction <= 0 {
+		return d
+	}
+	offset := (2*j.rand() - 1) * j.fraction * float64(d)
+	return d + time.Duration(offset)
+}
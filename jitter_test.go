@@ -0,0 +1,36 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestJitterApply(t *testing.T) {
+	const d = 10 * time.Second
+	tests := []struct {
+		r    float64
+		want time.Duration
+	}{
+		{0, 8 * time.Second},
+		{0.5, d},
+		{math.Nextafter(1, 0), 12 * time.Second},
+	}
+	for _, tt := range tests {
+		r := tt.r
+		j := jitter{fraction: 0.2, rand: func() float64 { return r }}
+		got := j.apply(d)
+		if diff := got - tt.want; diff < -time.Microsecond || diff > time.Microsecond {
+			t.Errorf("rand=%v: got %s, want about %s", tt.r, got, tt.want)
+		}
+		if got < 8*time.Second || got > 12*time.Second {
+			t.Errorf("rand=%v: got %s, out of bounds", tt.r, got)
+		}
+	}
+
+	// No jitter without a fraction; rand isn't called.
+	j := jitter{}
+	if got := j.apply(d); got != d {
+		t.Errorf("zero fraction: got %s, want %s", got, d)
+	}
+}
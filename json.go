@@ -0,0 +1,175 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"time"
+
+	"github.com/nishanths/lyft-go"
+)
+
+// rideJSON is the JSON output for a ride, used with the -json flag. It
+// mirrors the API's field names, but durations are formatted like "4m0s"
+// and times in RFC 3339 format, and fields that aren't set are omitted.
+type rideJSON struct {
+	RideID              string                `json:"ride_id"`
+	Status              string                `json:"status"`
+	RideType            string                `json:"ride_type"`
+	Origin              *rideLocationJSON     `json:"origin,omitempty"`
+	Pickup              *rideLocationJSON     `json:"pickup,omitempty"`
+	Destination         *rideLocationJSON     `json:"destination,omitempty"`
+	Dropoff             *rideLocationJSON     `json:"dropoff,omitempty"`
+	Location            *lyft.VehicleLocation `json:"location,omitempty"`
+	Driver              *personJSON           `json:"driver,omitempty"`
+	Vehicle             *vehicleJSON          `json:"vehicle,omitempty"`
+	PrimetimePercentage string                `json:"primetime_percentage,omitempty"`
+	DistanceMiles       float64               `json:"distance_miles,omitempty"`
+	Duration            string                `json:"duration,omitempty"`
+	Price               *lyft.Price           `json:"price,omitempty"`
+	Requested           string                `json:"requested_at,omitempty"`
+	RideProfile         string                `json:"ride_profile,omitempty"`
+	CanceledBy          string                `json:"canceled_by,omitempty"`
+}
+
+type rideLocationJSON struct {
+	Lat     float64 `json:"lat"`
+	Lng     float64 `json:"lng"`
+	Address string  `json:"address,omitempty"`
+	ETA     string  `json:"eta,omitempty"`
+	Time    string  `json:"time,omitempty"`
+}
+
+// personJSON and vehicleJSON are like lyft.Person and lyft.Vehicle, but
+// omit the fields that aren't set, such as the driver's user ID, which
+// the API doesn't return.
+type personJSON struct {
+	UserID    string `json:"user_id,omitempty"`
+	FirstName string `json:"first_name,omitempty"`
+	LastName  string `json:"last_name,omitempty"`
+	ImageURL  string `json:"image_url,omitempty"`
+	Rating    string `json:"rating,omitempty"`
+	Phone     string `json:"phone_number,omitempty"`
+}
+
+type vehicleJSON struct {
+	Make              string `json:"make,omitempty"`
+	Model             string `json:"model,omitempty"`
+	Year              int    `json:"year,omitempty"`
+	LicensePlate      string `json:"license_plate,omitempty"`
+	LicensePlateState string `json:"license_plate_state,omitempty"`
+	Color             string `json:"color,omitempty"`
+	ImageURL          string `json:"image_url,omitempty"`
+}
+
+func newRideJSON(detail lyft.RideDetail) rideJSON {
+	r := rideJSON{
+		RideID:              detail.RideID,
+		Status:              detail.RideStatus,
+		RideType:            detail.RideType,
+		Origin:              newRideLocationJSON(detail.Origin),
+		Pickup:              newRideLocationJSON(detail.Pickup),
+		Destination:         newRideLocationJSON(detail.Destination),
+		Dropoff:             newRideLocationJSON(detail.Dropoff),
+		PrimetimePercentage: detail.PrimetimePercentage,
+		DistanceMiles:       detail.Distance,
+		Duration:            jsonDuration(detail.Duration),
+		Requested:           jsonTime(detail.Requested),
+		RideProfile:         detail.RideProfile,
+		CanceledBy:          detail.CanceledBy,
+	}
+	if l := detail.Location; l.Latitude != 0 || l.Longitude != 0 {
+		r.Location = &l
+	}
+	if d := detail.Driver; d != (lyft.Person{}) {
+		r.Driver = &personJSON{
+			UserID:    d.UserID,
+			FirstName: d.FirstName,
+			LastName:  d.LastName,
+			ImageURL:  d.ImageURL,
+			Rating:    d.Rating,
+			Phone:     d.Phone,
+		}
+	}
+	if v := detail.Vehicle; v != (lyft.Vehicle{}) {
+		r.Vehicle = &vehicleJSON{
+			Make:              v.Make,
+			Model:             v.Model,
+			Year:              v.Year,
+			LicensePlate:      v.LicensePlate,
+			LicensePlateState: v.LicensePlateState,
+			Color:             v.Color,
+			ImageURL:          v.ImageURL,
+		}
+	}
+	if p := detail.Price; p != (lyft.Price{}) {
+		r.Price = &p
+	}
+	return r
+}
+
+// newRideLocationJSON returns nil if l isn't set.
+func newRideLocationJSON(l lyft.RideLocation) *rideLocationJSON {
+	if l == (lyft.RideLocation{}) {
+		return nil
+	}
+	return &rideLocationJSON{
+		Lat:     l.Latitude,
+		Lng:     l.Longitude,
+		Address: l.Address,
+		ETA:     jsonDuration(l.ETA),
+		Time:    jsonTime(l.Time),
+	}
+}
+
+// placeJSON is the JSON output for a saved place, used with -json. Unlike
+// places.json, whose format may change, the field names are fixed.
+type placeJSON struct {
+	Lat     float64 `json:"lat"`
+	Lng     float64 `json:"lng"`
+	Address string  `json:"address"`
+}
+
+func newPlaceJSON(l Location) placeJSON {
+	return placeJSON{Lat: l.Lat, Lng: l.Lng, Address: l.Address}
+}
+
+// placesJSON returns the JSON output for the saved places, keyed by name.
+// encoding/json encodes the names in sorted order, so the output is
+// stable.
+func placesJSON(places map[string]Location) map[string]placeJSON {
+	out := make(map[string]placeJSON, len(places))
+	for n, p := range places {
+		out[n] = newPlaceJSON(p)
+	}
+	return out
+}
+
+// jsonDuration returns an empty string for a zero duration, so that it
+// is omitted.
+func jsonDuration(d time.Duration) string {
+	if d == 0 {
+		return ""
+	}
+	return d.String()
+}
+
+// jsonTime returns an empty string for the zero time, so that it is
+// omitted.
+func jsonTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+// writeJSON writes v to standard output as indented JSON. It logs a
+// fatal error if v can't be marshaled.
+func writeJSON(v interface{}) {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		log.Fatalf("marshaling JSON: %s", err)
+	}
+	b = append(b, '\n')
+	os.Stdout.Write(b)
+}
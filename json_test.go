@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/nishanths/lyft-go"
+)
+
+func TestPlacesJSON(t *testing.T) {
+	places := map[string]Location{
+		"work":    {Lat: 37.7763, Lng: -122.3918, Address: "185 Berry St"},
+		"home":    {Lat: 37.7711, Lng: -122.4317},
+		"airport": {Lat: 37.6213, Lng: -122.379, Address: "SFO"},
+	}
+	want := `{"airport":{"lat":37.6213,"lng":-122.379,"address":"SFO"},` +
+		`"home":{"lat":37.7711,"lng":-122.4317,"address":""},` +
+		`"work":{"lat":37.7763,"lng":-122.3918,"address":"185 Berry St"}}`
+	for i := 0; i < 10; i++ {
+		b, err := json.Marshal(placesJSON(places))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != want {
+			t.Fatalf("got %s, want %s", b, want)
+		}
+	}
+
+	// No places is an empty object, not null.
+	b, err := json.Marshal(placesJSON(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "{}" {
+		t.Errorf("no places: got %s, want {}", b)
+	}
+}
+
+func TestNewRideJSON(t *testing.T) {
+	pdt := time.FixedZone("PDT", -7*60*60)
+	detail := lyft.RideDetail{
+		RideID:      "1",
+		RideStatus:  lyft.StatusAccepted,
+		RideType:    lyft.RideTypeLyft,
+		Origin:      lyft.RideLocation{Latitude: 37.7711, Longitude: -122.4317, Address: "1 Haight St", ETA: 4 * time.Minute},
+		Destination: lyft.RideLocation{Latitude: 37.7763, Longitude: -122.3918},
+		Driver:      lyft.Person{FirstName: "Alex", Rating: "4.9"},
+		Vehicle:     lyft.Vehicle{Make: "Toyota", Model: "Prius", Year: 2016, LicensePlate: "7ABC123"},
+		Duration:    16 * time.Minute,
+		Requested:   time.Date(2017, 10, 20, 16, 58, 0, 0, pdt),
+	}
+	b, err := json.Marshal(newRideJSON(detail))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"ride_id":"1","status":"accepted","ride_type":"lyft",` +
+		`"origin":{"lat":37.7711,"lng":-122.4317,"address":"1 Haight St","eta":"4m0s"},` +
+		`"destination":{"lat":37.7763,"lng":-122.3918},` +
+		`"driver":{"first_name":"Alex","rating":"4.9"},` +
+		`"vehicle":{"make":"Toyota","model":"Prius","year":2016,"license_plate":"7ABC123"},` +
+		`"duration":"16m0s","requested_at":"2017-10-20T16:58:00-07:00"}`
+	if string(b) != want {
+		t.Errorf("got  %s\nwant %s", b, want)
+	}
+
+	// A ride with only the required fields has no empty objects.
+	b, err = json.Marshal(newRideJSON(lyft.RideDetail{RideID: "1", RideStatus: lyft.StatusPending, RideType: lyft.RideTypeLyft}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"ride_id":"1","status":"pending","ride_type":"lyft"}`; string(b) != want {
+		t.Errorf("got  %s\nwant %s", b, want)
+	}
+}
@@ -3,7 +3,7 @@ Command lyft can request and manage Lyft rides from the command line.
 
 Usage
 
-  lyft [flags] [ride|place] [subcommand args...]
+  lyft [flags] [ride|place|prefs|cost|eta|history] [subcommand args...]
 
 Flags
 
@@ -15,24 +15,130 @@ The command's optional flags are:
   -notify            Show desktop notifications (default false), macOS only.
   -from <place>      Use saved place as the start location for the ride.
   -watch             Watch ride status updates (default false).
+  -template <tmpl>   Format output using a Go template or a built-in template name.
+  -no-persist        Don't save access tokens to disk (default false).
+  -mock              Use canned API responses; no rides are created (default false).
+  -sandbox           Use Lyft's sandbox environment (default false).
+  -json              Print ride status, ride list, and place show as JSON (default false).
 
 Ride subcommand
 
-The ride subcommand can create, cancel, and track the status of rides.
+The ride subcommand can create, cancel, and track the status of rides, and
+print ride receipts. With -full, the receipt also includes the date, route,
+driver, and vehicle, in a plain-text form suitable for expense reports. A
+receipt isn't available until shortly after the ride is dropped off; with
+-wait, receipt keeps checking until it is. The ics subcommand prints the
+ride as an iCalendar event, which can be imported into calendar
+applications. The rebook subcommand requests a new ride with the same start
+location, end location, and ride type as an earlier ride, for example one
+that was canceled. The update subcommand changes the end location of a ride
+that is in progress; the -to flag can be used to specify a saved place. The
+list subcommand prints the rides requested in the last 30 days, or in the
+duration specified with -since (for example, 168h for the last week).
 
   lyft ride create
   lyft ride cancel <ride-id>
   lyft ride status <ride-id>
+  lyft ride receipt <ride-id> [-full] [-wait]
+  lyft ride ics     <ride-id>
+  lyft ride rebook  <ride-id>
+  lyft ride update  <ride-id>
+  lyft ride list    [-since <duration>]
 
 Place subcommand
 
 The place subcommand can save ride start and end locations for future use,
 so you don't have to enter full addresses each time you create a ride. If
-a name isn't specified, the show subcommand prints all saved places.
+a name isn't specified, the show subcommand prints all saved places, sorted
+by name. With -json, show prints the places in a fixed format meant for
+other programs: an object mapping each name to an object with "lat",
+"lng", and "address" fields, or just the latter object if a name is
+specified.
 
   lyft place add    <name>
   lyft place remove <name>...
-  lyft place show   [name]
+  lyft place show   [name] [-json]
+
+Prefs subcommand
+
+The prefs subcommand saves default values for the -type, -notify, and
+-watch flags. Flags specified on the command line take precedence over
+saved preferences, which take precedence over the flags' defaults. The
+history preference turns on saving completed rides locally (see the
+history subcommand). The region preference, for example a city or a
+country, is appended to street addresses that can't be found as entered,
+and the address is looked up again.
+
+  lyft prefs set  <key> <value>
+  lyft prefs show
+
+For example:
+
+  lyft prefs set type lyft
+  lyft prefs set notify true
+  lyft prefs set region "San Francisco, CA"
+
+Estimate subcommands
+
+The cost and eta subcommands print cost and driver ETA estimates for each
+ride type. Saved places can be used for the start and end locations via the
+-from and -to flags; otherwise the locations are prompted for. The end
+location is optional for eta; if it is specified with -to, eta also prints
+the expected arrival time at the end location, based on the driver ETA
+and the estimated ride duration.
+
+  lyft -from home -to work cost
+  lyft -from home eta
+
+History subcommand
+
+The history subcommand prints the rides requested in the last 30 days. If
+the history preference is set, completed rides are also saved to a local
+history file, either when a watched ride is dropped off or when they are
+listed by the history or ride list subcommands. With -local, the locally
+saved rides are included too, so that rides older than the API's window can
+be listed. With -status, only rides with the status (for example,
+droppedOff or canceled) are listed.
+
+  lyft history [-local] [-status <status>]
+
+For example:
+
+  lyft prefs set history true
+  lyft history -local
+
+Output templates
+
+The -template flag formats the output of the ride status, cost, and eta
+subcommands using a text/template. The template is applied to each
+lyft.RideDetail, lyft.CostEstimate, or lyft.ETAEstimate respectively, and
+can use the functions cents, rideType, and status to format amounts, ride
+types, and ride statuses. These built-in templates can be used by name.
+
+  status  Ride ID and status (ride status)
+  driver  Driver, vehicle, and pickup ETA (ride status)
+  cost    Ride type and cost range (cost)
+  eta     Ride type and driver ETA (eta)
+
+For example:
+
+  lyft -watch -template '{{.RideStatus}} {{.Origin.ETA}}' ride status <ride-id>
+  lyft -template cost -from home -to work cost
+
+JSON output
+
+The -json flag prints the output of the ride status and ride list
+subcommands as JSON, one object per status update when watching and an
+array of rides for list, for use with tools such as jq. The field names
+follow the Lyft API's; durations are formatted like "4m0s", times are in
+RFC 3339 format, and fields that aren't set are omitted.
+
+  lyft -json ride status <ride-id> | jq -r .status
+
+The -json flag also applies to place show; see the place subcommand.
+
+Using -template or -json with a subcommand whose output they don't apply
+to is an error.
 
 Location input
 
@@ -51,7 +157,8 @@ The program uses the following environment variables.
   LYFT_CLIENT_SECRET
 
 GOOG_GEOCODE_KEY is the Google Maps Geocode API key used to geocode street
-addresses. It can be obtained from:
+addresses. It isn't needed if locations are only entered as lat,lng pairs.
+It can be obtained from:
 
   https://developers.google.com/maps/documentation/geocoding/get-api-key
 
@@ -69,12 +176,35 @@ Notes
 
 Only one passenger is supported for Lyft Line rides requested by the program.
 
+Rides with multiple stops are not supported: Lyft's ride request API accepts
+a single end location, with no waypoints.
+
 You will receive notifications via your smartphone's Lyft app as you usual
 for rides created by this program, so you can skip the -notify and -watch
 flags if you wish.
 
 The program stores program-relevant data in a directory named ".lyft" in the
-user's home directory.
+user's home directory. Access tokens are not saved there if the -no-persist
+flag is specified or if the LYFT_NO_PERSIST environment variable is set to
+a non-empty value, which is useful in ephemeral environments such as CI.
+Tokens that were already saved are still used, but refreshed tokens aren't
+saved; without saved tokens, authorization is requested each time the
+program is run.
+
+The -mock flag makes the program use canned responses from an in-process
+server instead of the Lyft API, which is useful for demos and for trying
+out the program. No Lyft API keys or authorization are needed, and no real
+rides are created, canceled, or charged for. Street addresses still
+require GOOG_GEOCODE_KEY, but lat,lng pairs and saved places work without
+it.
+
+  lyft -mock -from home -to work cost
+  lyft -mock -watch ride create
+
+The -sandbox flag makes the program use Lyft's sandbox environment, in
+which ride requests are simulated (see https://developer.lyft.com/v1/docs/sandbox).
+Sandbox tokens are stored separately from production tokens and are never
+used for production requests, or vice versa.
 */
 package main
 
@@ -90,14 +220,13 @@ import (
 	"runtime"
 	"strings"
 	"text/tabwriter"
+	"text/template"
 
 	"github.com/nishanths/lyft-go"
 	"googlemaps.github.io/maps"
 )
 
-// TODO: implement ride update <ride-id>
-
-const help = `usage: lyft [flags] [ride|place] [subcommand args...]
+const help = `usage: lyft [flags] [ride|place|prefs|cost|eta|history] [subcommand args...]
 
 Flags
 
@@ -107,18 +236,45 @@ Flags
   -notify            Show desktop notifications (default false), macOS only.
   -from <place>      Use saved place as the start location for the ride.
   -watch             Watch ride status updates (default false).
+  -template <tmpl>   Format output using a Go template or a built-in template name.
+  -no-persist        Don't save access tokens to disk (default false).
+  -mock              Use canned API responses; no rides are created (default false).
+  -sandbox           Use Lyft's sandbox environment (default false).
+  -json              Print ride status, ride list, and place show as JSON (default false).
 
-The ride subcommand can create, cancel, and track the status of rides.
+The ride subcommand can create, cancel, and track the status of rides,
+and print ride receipts and calendar events.
 
   lyft ride create
   lyft ride cancel <ride-id>
   lyft ride status <ride-id>
+  lyft ride receipt <ride-id> [-full] [-wait]
+  lyft ride ics     <ride-id>
+  lyft ride rebook  <ride-id>
+  lyft ride update  <ride-id>
+  lyft ride list    [-since <duration>]
 
 The place subcommand can save ride start and end locations for future use.
 
   lyft place add    <name>
   lyft place remove <name>...
-  lyft place show   [name]
+  lyft place show   [name] [-json]
+
+The prefs subcommand can save default values for the -type, -notify, and
+-watch flags.
+
+  lyft prefs set  <key> <value>
+  lyft prefs show
+
+The cost and eta subcommands print cost and driver ETA estimates.
+
+  lyft cost
+  lyft eta
+
+The history subcommand prints recent rides, including locally saved ones
+with -local.
+
+  lyft history [-local] [-status <status>]
 
 The program uses the following environment variables.
 
@@ -135,9 +291,12 @@ func usage() {
 }
 
 const (
-	rootDir      = ".lyft"
-	internalFile = "internal.json"
-	placesFile   = "places.json"
+	rootDir             = ".lyft"
+	internalFile        = "internal.json"
+	sandboxInternalFile = "internal-sandbox.json"
+	placesFile          = "places.json"
+	prefsFile           = "prefs.json"
+	historyFile         = "history.json"
 )
 
 const (
@@ -155,6 +314,11 @@ func main() {
 	notifications := flag.Bool("notify", false, "")
 	dryRun := flag.Bool("dry-run", false, "")
 	watch := flag.Bool("watch", false, "")
+	tmpl := flag.String("template", "", "")
+	noPersist := flag.Bool("no-persist", false, "")
+	mock := flag.Bool("mock", false, "")
+	sandbox := flag.Bool("sandbox", false, "")
+	jsonOutput := flag.Bool("json", false, "")
 
 	flag.Usage = usage
 	flag.Parse()
@@ -164,6 +328,17 @@ func main() {
 		usage()
 	}
 
+	t, err := parseOutputTemplate(*tmpl)
+	if err != nil {
+		log.Fatalf("parsing template: %s", err)
+	}
+	if t != nil && *jsonOutput {
+		log.Fatalf("cannot use both -template and -json")
+	}
+	if err := checkOutputFlags(args, t != nil, *jsonOutput); err != nil {
+		log.Fatal(err)
+	}
+
 	flags := Flags{
 		car:           *car,
 		startPlace:    *startPlace,
@@ -171,13 +346,41 @@ func main() {
 		notifications: *notifications,
 		dryRun:        *dryRun,
 		watch:         *watch || *notifications,
+		template:      t,
+		noPersist:     *noPersist || os.Getenv("LYFT_NO_PERSIST") != "",
+		mock:          *mock,
+		sandbox:       *sandbox,
+		json:          *jsonOutput,
+	}
+
+	// The prefs command reads the prefs itself, so that a malformed
+	// prefs file can be fixed using it.
+	if args[0] != "prefs" {
+		prefs, err := readPrefs()
+		if err != nil {
+			log.Fatalf("reading prefs: %s\n%s", err, prefsHint)
+		}
+		set := make(map[string]bool)
+		flag.Visit(func(f *flag.Flag) { set[f.Name] = true })
+		prefs.apply(&flags, set)
+	}
+	if flags.mock {
+		flags.history = false // don't mix mock rides into the real history
 	}
 
 	switch args[0] {
 	case "ride":
 		cmdRide(args[1:], flags)
 	case "place":
-		cmdPlace(args[1:])
+		cmdPlace(args[1:], flags)
+	case "prefs":
+		cmdPrefs(args[1:])
+	case "cost":
+		cmdCost(args[1:], flags)
+	case "eta":
+		cmdETA(args[1:], flags)
+	case "history":
+		cmdHistory(args[1:], flags)
 	default:
 		usage()
 	}
@@ -192,6 +395,13 @@ type Flags struct {
 	notifications bool
 	dryRun        bool
 	watch         bool
+	template      *template.Template // nil if not set
+	noPersist     bool
+	history       bool // save completed rides to the local history
+	mock          bool
+	sandbox       bool
+	json          bool
+	region        string // appended to addresses that aren't found
 }
 
 // rideType returns the ride type for the specified flag,
@@ -233,7 +443,8 @@ type Location struct {
 // parseLocationInput attempts to parse str as as lat,lng pair
 // or a street address. The maps client function is invoked
 // only if str was not a lat,lng (and hence geocoding is required).
-func parseLocationInput(str string, mapsc func() *maps.Client) (Location, error) {
+// region is used as described in locationFromStreetAddress.
+func parseLocationInput(str string, mapsc func() (*maps.Client, error), region string) (Location, error) {
 	// Does it look like a lat,lng?
 	// NOTE: we need to check that the length is at least 2, otherwise
 	// maps.ParseLatLng panics internally due to an out of bounds access.
@@ -241,13 +452,28 @@ func parseLocationInput(str string, mapsc func() *maps.Client) (Location, error)
 		// Let's try parsing. If it succeeds, it was a latitude/longitude.
 		ll, err := maps.ParseLatLng(str)
 		if err == nil {
+			// The API doesn't reject out of range coordinates clearly, so
+			// check them here.
+			if ll.Lat < -90 || ll.Lat > 90 {
+				return Location{}, fmt.Errorf("latitude %v out of range; must be between -90 and 90", ll.Lat)
+			}
+			if ll.Lng < -180 || ll.Lng > 180 {
+				return Location{}, fmt.Errorf("longitude %v out of range; must be between -180 and 180", ll.Lng)
+			}
 			return Location{ll.Lat, ll.Lng, ""}, nil
 		}
 	}
 	// OK, need to geocode street address.
-	loc, err := locationFromStreetAddress(str, mapsc())
+	c, err := mapsc()
+	if err == errNoGeocodeKey {
+		return Location{}, err
+	}
 	if err != nil {
-		return Location{}, fmt.Errorf("failed to determine coordinates for address %q: %s", "", err)
+		return Location{}, fmt.Errorf("making google maps client: %s", err)
+	}
+	loc, err := locationFromStreetAddress(str, c, region)
+	if err != nil {
+		return Location{}, fmt.Errorf("failed to determine coordinates for address %q: %s", str, err)
 	}
 	return loc, nil
 }
@@ -255,12 +481,23 @@ func parseLocationInput(str string, mapsc func() *maps.Client) (Location, error)
 // locationFromStreetAddress constructs a Location for the street address a.
 // The returned Location's Address field may not be the same
 // value as the supplied street address. It is typically a cleaned-up form.
-func locationFromStreetAddress(a string, mapsc *maps.Client) (Location, error) {
+//
+// If there are no results for the address and region is non-empty, the
+// address is geocoded once more with region (for example, a city or a
+// country) appended, since addresses entered without one are sometimes
+// not found.
+func locationFromStreetAddress(a string, mapsc *maps.Client, region string) (Location, error) {
 	ctx := context.TODO()
 	results, err := mapsc.Geocode(ctx, &maps.GeocodingRequest{Address: a})
 	if err != nil {
 		return Location{}, err
 	}
+	if len(results) == 0 && region != "" && !strings.Contains(strings.ToLower(a), strings.ToLower(region)) {
+		results, err = mapsc.Geocode(ctx, &maps.GeocodingRequest{Address: a + ", " + region})
+		if err != nil {
+			return Location{}, err
+		}
+	}
 	if len(results) == 0 {
 		// Can this happen? Wish they would document this; they literally
 		// own both the HTTP API and this client, so it really isn't that hard.
@@ -334,6 +571,39 @@ func googleMapsURL(lat, lng float64) string {
 	return fmt.Sprintf("https://www.google.com/maps/place/%f,%f", lat, lng)
 }
 
+// formatCents formats an amount in cents, such as the ones in
+// cost estimates, for display.
+func formatCents(n int) string {
+	return formatAmount(n, "USD")
+}
+
+// formatAmount formats an amount in the smallest unit of the ISO 4217
+// currency (e.g. cents for USD) for display.
+func formatAmount(n int, currency string) string {
+	var sign string
+	if n < 0 {
+		sign = "-"
+		n = -n
+	}
+	if currency == "USD" {
+		return fmt.Sprintf("%s$%d.%02d", sign, n/100, n%100)
+	}
+	return fmt.Sprintf("%s%d.%02d %s", sign, n/100, n%100, currency)
+}
+
 func standardTabWriter() *tabwriter.Writer {
 	return tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
 }
+
+// parseArgs parses the flags in args using fs, and returns the first
+// argument that isn't a flag, or an empty string if there isn't one. The
+// argument can come before, after, or between the flags.
+func parseArgs(fs *flag.FlagSet, args []string) string {
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		return ""
+	}
+	arg := fs.Arg(0)
+	fs.Parse(fs.Args()[1:])
+	return arg
+}
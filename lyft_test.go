@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"runtime"
+	"testing"
+
+	"googlemaps.github.io/maps"
+)
+
+// tempHome points the home directory returned by HOME at a new temporary
+// directory for the duration of the test, and returns the directory.
+func tempHome(t *testing.T) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "lyft-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	key := "HOME"
+	if runtime.GOOS == "windows" {
+		key = "HOMEPATH"
+	}
+	setenv(t, key, dir)
+	return dir
+}
+
+// setenv sets the environment variable for the duration of the test. An
+// empty value unsets the variable.
+func setenv(t *testing.T, key, value string) {
+	t.Helper()
+	old, ok := os.LookupEnv(key)
+	if value == "" {
+		os.Unsetenv(key)
+	} else {
+		os.Setenv(key, value)
+	}
+	t.Cleanup(func() {
+		if ok {
+			os.Setenv(key, old)
+		} else {
+			os.Unsetenv(key)
+		}
+	})
+}
+
+func TestParseLocationInputNoGeocodeKey(t *testing.T) {
+	setenv(t, geocodeEnv, "")
+	called := false
+	mapsc := func() (*maps.Client, error) {
+		called = true
+		_, err := geocodeKey()
+		return nil, err
+	}
+
+	loc, err := parseLocationInput("37.7749,-122.4194", mapsc, "")
+	if err != nil {
+		t.Fatalf("lat,lng: %s", err)
+	}
+	if loc != (Location{Lat: 37.7749, Lng: -122.4194}) {
+		t.Errorf("lat,lng: got %+v", loc)
+	}
+	if called {
+		t.Error("lat,lng: maps client requested, but no geocoding is needed")
+	}
+
+	if _, err := parseLocationInput("1 Market St", mapsc, ""); err != errNoGeocodeKey {
+		t.Errorf("street address: got error %v, want errNoGeocodeKey", err)
+	}
+}
+
+// geocodeServer starts a Geocoding API server that only has results for
+// the address known, and records the addresses requested.
+func geocodeServer(t *testing.T, known string, requested *[]string) *maps.Client {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		addr := r.URL.Query().Get("address")
+		*requested = append(*requested, addr)
+		w.Header().Set("Content-Type", "application/json")
+		if addr != known {
+			fmt.Fprint(w, `{"results": [], "status": "ZERO_RESULTS"}`)
+			return
+		}
+		fmt.Fprintf(w, `{"results": [{"formatted_address": %q, "geometry": {"location": {"lat": 37.7609, "lng": -122.435}}}], "status": "OK"}`, known+", USA")
+	}))
+	t.Cleanup(srv.Close)
+	c, err := maps.NewClient(maps.WithAPIKey("test"), maps.WithBaseURL(srv.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestLocationFromStreetAddressRegion(t *testing.T) {
+	const region = "San Francisco, CA"
+	const known = "500 Castro St, " + region
+
+	// Zero results, then a hit with the region appended.
+	var requested []string
+	c := geocodeServer(t, known, &requested)
+	loc, err := locationFromStreetAddress("500 Castro St", c, region)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Location{Lat: 37.7609, Lng: -122.435, Address: known + ", USA"}
+	if loc != want {
+		t.Errorf("got %+v, want %+v", loc, want)
+	}
+	if len(requested) != 2 || requested[0] != "500 Castro St" || requested[1] != known {
+		t.Errorf("got requests %q", requested)
+	}
+
+	// The region is already in the address: no retry.
+	requested = nil
+	if _, err := locationFromStreetAddress("1 Nowhere St, san francisco, ca", c, region); err == nil {
+		t.Error("expected error for address without results")
+	}
+	if len(requested) != 1 {
+		t.Errorf("got requests %q, want one", requested)
+	}
+
+	// No region: no retry.
+	requested = nil
+	if _, err := locationFromStreetAddress("500 Castro St", c, ""); err == nil {
+		t.Error("expected error for address without results")
+	}
+	if len(requested) != 1 {
+		t.Errorf("got requests %q, want one", requested)
+	}
+}
+
+func TestParseLocationInputRange(t *testing.T) {
+	mapsc := func() (*maps.Client, error) {
+		t.Error("maps client requested for lat,lng input")
+		return nil, errNoGeocodeKey
+	}
+	tests := []struct {
+		in string
+		ok bool
+	}{
+		{"90,0", true},
+		{"-90,0", true},
+		{"0,180", true},
+		{"0,-180", true},
+		{"90,-180", true},
+		{"90.0001,0", false},
+		{"-90.0001,0", false},
+		{"0,180.0001", false},
+		{"0,-180.0001", false},
+	}
+	for _, tt := range tests {
+		_, err := parseLocationInput(tt.in, mapsc, "")
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: got error %v, want ok=%t", tt.in, err, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+
+	"github.com/nishanths/lyft-go"
+)
+
+// mockRideID is the ID of the ride returned by the mock server.
+const mockRideID = "123456789"
+
+// mockStatuses is the sequence of statuses the mock ride goes through.
+// Each request for the ride's details advances the ride to the next
+// status, so that watching the ride shows every status.
+var mockStatuses = []string{
+	lyft.StatusPending,
+	lyft.StatusAccepted,
+	lyft.StatusArrived,
+	lyft.StatusPickedUp,
+	lyft.StatusDroppedOff,
+}
+
+// startMockServer starts an in-process server that responds to Lyft API
+// requests with canned responses, and returns its URL. No rides are
+// created. The server runs until the program exits.
+func startMockServer() string {
+	var mu sync.Mutex
+	n := 0 // number of ride detail requests so far
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/cost", func(w http.ResponseWriter, r *http.Request) {
+		writeMockJSON(w, http.StatusOK, mockCostEstimates)
+	})
+	mux.HandleFunc("/v1/eta", func(w http.ResponseWriter, r *http.Request) {
+		writeMockJSON(w, http.StatusOK, mockETAEstimates)
+	})
+	mux.HandleFunc("/v1/rides", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			writeMockJSON(w, http.StatusCreated, mockCreatedRide)
+			return
+		}
+		writeMockJSON(w, http.StatusOK, `{"ride_history": [`+fmt.Sprintf(mockRideDetail, lyft.StatusDroppedOff)+`]}`)
+	})
+	mux.HandleFunc("/v1/rides/", func(w http.ResponseWriter, r *http.Request) {
+		rest := strings.TrimPrefix(r.URL.Path, "/v1/rides/")
+		switch {
+		case strings.HasSuffix(rest, "/cancel"):
+			w.WriteHeader(http.StatusNoContent)
+		case strings.HasSuffix(rest, "/receipt"):
+			writeMockJSON(w, http.StatusOK, mockRideReceipt)
+		case strings.HasSuffix(rest, "/destination"):
+			writeMockJSON(w, http.StatusOK, `{"lat": 37.7763, "lng": -122.3918, "address": "185 Berry St, San Francisco, CA"}`)
+		default:
+			mu.Lock()
+			status := mockStatuses[n]
+			if n < len(mockStatuses)-1 {
+				n++
+			}
+			mu.Unlock()
+			writeMockJSON(w, http.StatusOK, fmt.Sprintf(mockRideDetail, status))
+		}
+	})
+
+	return httptest.NewServer(mux).URL
+}
+
+func writeMockJSON(w http.ResponseWriter, code int, body string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	fmt.Fprint(w, body)
+}
+
+const mockCostEstimates = `{"cost_estimates": [
+	{"ride_type": "lyft_line", "display_name": "Lyft Line", "estimated_cost_cents_min": 475, "estimated_cost_cents_max": 475, "estimated_distance_miles": 3.29, "estimated_duration_seconds": 913, "is_valid_estimate": true},
+	{"ride_type": "lyft", "display_name": "Lyft", "estimated_cost_cents_min": 1052, "estimated_cost_cents_max": 1755, "estimated_distance_miles": 3.29, "estimated_duration_seconds": 913, "is_valid_estimate": true},
+	{"ride_type": "lyft_plus", "display_name": "Lyft Plus", "estimated_cost_cents_min": 1658, "estimated_cost_cents_max": 2605, "estimated_distance_miles": 3.29, "estimated_duration_seconds": 913, "is_valid_estimate": true}
+]}`
+
+const mockETAEstimates = `{"eta_estimates": [
+	{"ride_type": "lyft_line", "display_name": "Lyft Line", "eta_seconds": 180, "is_valid_estimate": true},
+	{"ride_type": "lyft", "display_name": "Lyft", "eta_seconds": 120, "is_valid_estimate": true},
+	{"ride_type": "lyft_plus", "display_name": "Lyft Plus", "eta_seconds": 420, "is_valid_estimate": true}
+]}`
+
+const mockCreatedRide = `{
+	"ride_id": "` + mockRideID + `",
+	"status": "pending",
+	"ride_type": "lyft_line",
+	"origin": {"lat": 37.7711, "lng": -122.4317, "address": "1 Haight St, San Francisco, CA"},
+	"destination": {"lat": 37.7763, "lng": -122.3918, "address": "185 Berry St, San Francisco, CA"},
+	"passenger": {"user_id": "1", "first_name": "Sam"}
+}`
+
+// mockRideDetail is a format string; the ride's status is substituted.
+const mockRideDetail = `{
+	"ride_id": "` + mockRideID + `",
+	"status": "%s",
+	"ride_type": "lyft_line",
+	"origin": {"lat": 37.7711, "lng": -122.4317, "address": "1 Haight St, San Francisco, CA", "eta_seconds": 90},
+	"pickup": {"lat": 37.7711, "lng": -122.4317, "address": "1 Haight St, San Francisco, CA", "time": "2017-10-20T17:02:00-07:00"},
+	"destination": {"lat": 37.7763, "lng": -122.3918, "address": "185 Berry St, San Francisco, CA", "eta_seconds": 840},
+	"dropoff": {"lat": 37.7763, "lng": -122.3918, "address": "185 Berry St, San Francisco, CA", "time": "2017-10-20T17:18:00-07:00"},
+	"location": {"lat": 37.7725, "lng": -122.4209, "bearing": 90},
+	"passenger": {"user_id": "1", "first_name": "Sam"},
+	"driver": {"first_name": "Alex", "last_name": "Doe", "rating": "4.9"},
+	"vehicle": {"make": "Toyota", "model": "Prius", "year": 2016, "license_plate": "7ABC123", "license_plate_state": "CA", "color": "Blue"},
+	"distance_miles": 3.29,
+	"duration_seconds": 960,
+	"price": {"amount": 475, "currency": "USD", "description": "Lyft Line fare"},
+	"requested_at": "2017-10-20T16:58:00-07:00",
+	"ride_profile": "personal",
+	"can_cancel": ["driver", "passenger"]
+}`
+
+const mockRideReceipt = `{
+	"ride_id": "` + mockRideID + `",
+	"price": {"amount": 475, "currency": "USD", "description": "Lyft Line fare"},
+	"line_items": [{"amount": 475, "currency": "USD", "type": "Lyft Line fare"}],
+	"charges": [{"amount": 475, "currency": "USD", "payment_method": "Visa ending in 4242"}],
+	"requested_at": "2017-10-20T16:58:00-07:00",
+	"ride_profile": "personal"
+}`
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nishanths/lyft-go"
+)
+
+func newMockClient() *lyft.Client {
+	return newLyftClient(Internal{AccessToken: "mock", baseURL: startMockServer()})
+}
+
+func TestMockServerEndpoints(t *testing.T) {
+	c := newMockClient()
+
+	costs, _, err := c.CostEstimates(37.7711, -122.4317, 37.7763, -122.3918, "")
+	if err != nil {
+		t.Fatalf("CostEstimates: %s", err)
+	}
+	if len(costs) != 3 || costs[1].RideType != lyft.RideTypeLyft || costs[1].MinimumCost != 1052 || costs[1].Duration != 913*time.Second {
+		t.Errorf("CostEstimates: got %+v", costs)
+	}
+
+	etas, _, err := c.DriverETA(37.7711, -122.4317, lyft.IgnoreArg, lyft.IgnoreArg, "")
+	if err != nil {
+		t.Fatalf("DriverETA: %s", err)
+	}
+	if len(etas) != 3 || etas[0].ETA != 3*time.Minute || !etas[0].Valid {
+		t.Errorf("DriverETA: got %+v", etas)
+	}
+
+	created, _, err := c.RequestRide(lyft.RideRequest{
+		Origin:   lyft.Location{Latitude: 37.7711, Longitude: -122.4317},
+		RideType: lyft.RideTypeLine,
+	})
+	if err != nil {
+		t.Fatalf("RequestRide: %s", err)
+	}
+	if created.RideID != mockRideID || created.RideStatus != lyft.StatusPending {
+		t.Errorf("RequestRide: got %+v", created)
+	}
+
+	receipt, _, err := c.RideReceipt(mockRideID)
+	if err != nil {
+		t.Fatalf("RideReceipt: %s", err)
+	}
+	if receipt.RideID != mockRideID || receipt.Price.Amount != 475 || len(receipt.Charges) != 1 {
+		t.Errorf("RideReceipt: got %+v", receipt)
+	}
+
+	dest, _, err := c.SetDestination(mockRideID, lyft.Location{Latitude: 37.7763, Longitude: -122.3918})
+	if err != nil {
+		t.Fatalf("SetDestination: %s", err)
+	}
+	if dest.Address != "185 Berry St, San Francisco, CA" {
+		t.Errorf("SetDestination: got %+v", dest)
+	}
+
+	rides, _, err := c.RideHistory(time.Now().Add(-historyWindow), time.Time{}, -1)
+	if err != nil {
+		t.Fatalf("RideHistory: %s", err)
+	}
+	if len(rides) != 1 || rides[0].RideStatus != lyft.StatusDroppedOff {
+		t.Errorf("RideHistory: got %+v", rides)
+	}
+
+	if _, err := c.CancelRide(mockRideID, ""); err != nil {
+		t.Errorf("CancelRide: %s", err)
+	}
+}
+
+func TestMockServerStatusProgression(t *testing.T) {
+	c := newMockClient()
+	for i, want := range mockStatuses {
+		detail, _, err := c.RideDetail(mockRideID)
+		if err != nil {
+			t.Fatalf("RideDetail %d: %s", i, err)
+		}
+		if detail.RideStatus != want {
+			t.Errorf("RideDetail %d: got status %s, want %s", i, detail.RideStatus, want)
+		}
+		if detail.RideID != mockRideID || detail.Vehicle.LicensePlate != "7ABC123" {
+			t.Errorf("RideDetail %d: got %+v", i, detail)
+		}
+	}
+
+	// The ride stays dropped off.
+	detail, _, err := c.RideDetail(mockRideID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if detail.RideStatus != lyft.StatusDroppedOff {
+		t.Errorf("after the last status: got %s, want %s", detail.RideStatus, lyft.StatusDroppedOff)
+	}
+}
@@ -2,65 +2,39 @@ package main
 
 import (
 	"encoding/json"
+	"flag"
 	"fmt"
-	"io/ioutil"
 	"log"
 	"os"
-	"path/filepath"
 )
 
-func cmdPlace(args []string) {
+func cmdPlace(args []string, flags Flags) {
 	if len(args) == 0 {
 		usage()
 	}
 
-	home := HOME()
-
 	switch args[0] {
 	case "add":
-		cmdPlaceAdd(args[1:], home)
+		cmdPlaceAdd(args[1:], flags.region)
 	case "remove":
-		cmdPlaceRemove(args[1:], home)
+		cmdPlaceRemove(args[1:])
 	case "show":
-		cmdPlaceShow(args[1:], home)
+		cmdPlaceShow(args[1:], flags.json)
 	default:
 		usage()
 	}
 }
 
-func cmdPlaceAdd(args []string, home string) {
+func cmdPlaceAdd(args []string, region string) {
 	// Whoops?
 	if len(args) == 0 {
 		log.Fatalf("must specify a <name> for the place to add")
 	}
 	name := args[0]
 
-	if err := os.MkdirAll(filepath.Join(home, rootDir), permRootDir); err != nil {
-		log.Fatalf("making %s directory: %s", rootDir, err)
-	}
-
-	// Does the places file exist?
-	_, err := os.Stat(filepath.Join(home, rootDir, placesFile))
-	if err != nil {
-		if os.IsNotExist(err) {
-			// Create an empty file. That way, the code below can have less
-			// branching.
-			if err := ioutil.WriteFile(filepath.Join(home, rootDir, placesFile), []byte("{}"), permFile); err != nil {
-				log.Fatalf("creating places.json: %s", err)
-			}
-		} else {
-			log.Fatalf("stat places.json: %s", err)
-		}
-	}
-
-	// Parse the existing places.
-	b, err := ioutil.ReadFile(filepath.Join(home, rootDir, placesFile))
+	existing, err := readPlaces()
 	if err != nil {
-		log.Fatalf("reading places.json: %s", err)
-	}
-	var existing map[string]Location
-	if err := json.Unmarshal(b, &existing); err != nil {
-		log.Fatalf("unmarshaling places: %s", err)
+		log.Fatalf("reading places: %s", err)
 	}
 
 	// Reject if named place already exists.
@@ -68,7 +42,7 @@ func cmdPlaceAdd(args []string, home string) {
 		log.Fatalf("place %q already exists; remove before re-adding", name)
 	}
 
-	loc, err := parseLocationInput(interactiveInput("Enter location (street address or lat,lng): "), mapsClient)
+	loc, err := parseLocationInput(interactiveInput("Enter location (street address or lat,lng): "), mapsClient, region)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -105,22 +79,18 @@ func printRoute(startLoc, endLoc *Location) {
 	w.Flush()
 }
 
-func cmdPlaceRemove(args []string, home string) {
+func cmdPlaceRemove(args []string) {
 	if len(args) == 0 {
 		log.Fatalf("must specify a <name> for the place to remove")
 	}
 
-	b, err := ioutil.ReadFile(filepath.Join(home, rootDir, placesFile))
+	var existing map[string]Location
+	found, err := readStored(placesFile, &existing)
 	if err != nil {
-		if os.IsNotExist(err) {
-			log.Fatalf("no places found? not making any changes.")
-		}
-		log.Fatalf("opening places file: %s", err)
+		log.Fatalf("reading places: %s", err)
 	}
-
-	var existing map[string]Location
-	if err := json.Unmarshal(b, &existing); err != nil {
-		log.Fatalf("unmarshaling places: %s", err)
+	if !found {
+		log.Fatalf("no places found? not making any changes.")
 	}
 
 	for _, name := range args {
@@ -138,42 +108,51 @@ func cmdPlaceRemove(args []string, home string) {
 	os.Exit(0)
 }
 
-func cmdPlaceShow(args []string, home string) {
-	b, err := ioutil.ReadFile(filepath.Join(home, rootDir, placesFile))
-	if err != nil {
-		if os.IsNotExist(err) {
-			fmt.Fprintf(os.Stdout, "no existing places. add one using 'lyft place add <name>'.\n")
-			os.Exit(0)
-		}
-		log.Fatalf("opening places file: %s", err)
-	}
+func cmdPlaceShow(args []string, jsonOutput bool) {
+	fs := flag.NewFlagSet("show", flag.ExitOnError)
+	fs.BoolVar(&jsonOutput, "json", jsonOutput, "")
+	fs.Usage = usage
+	name := parseArgs(fs, args)
 
-	var places map[string]Location
-	if err := json.Unmarshal(b, &places); err != nil {
-		log.Fatalf("unmarshaling places: %s", err)
+	if name == "" {
+		printPlaces(jsonOutput)
 	}
 
-	if len(places) == 0 {
-		fmt.Fprintf(os.Stdout, "no existing places. add one using 'lyft place add <name>'.\n")
+	place, err := placeByName(name)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if jsonOutput {
+		writeJSON(newPlaceJSON(place))
 		os.Exit(0)
 	}
+	// Print it as JSON.
+	data, err := json.MarshalIndent(place, "", " ")
+	if err != nil {
+		log.Fatalf("marshaling places: %s", err)
+	}
+	fmt.Fprintf(os.Stdout, "%s\n", data)
+	os.Exit(0)
+}
 
-	// No name specified. Print all.
-	if len(args) == 0 {
-		// Print the raw JSON. We can rely on the fact that the object keys
-		// will be sorted, because they would have been written in sorted
-		// order initially.
-		fmt.Fprintf(os.Stdout, "%s\n", b)
+// printPlaces prints all the saved places and exits.
+func printPlaces(jsonOutput bool) {
+	var places map[string]Location
+	found, err := readStored(placesFile, &places)
+	if err != nil {
+		log.Fatalf("reading places: %s", err)
+	}
+	if jsonOutput {
+		writeJSON(placesJSON(places))
 		os.Exit(0)
 	}
-
-	name := args[0]
-	place, ok := places[name]
-	if !ok {
-		log.Fatalf("place %q not found", name)
+	if !found || len(places) == 0 {
+		fmt.Fprintf(os.Stdout, "no existing places. add one using 'lyft place add <name>'.\n")
+		os.Exit(0)
 	}
-	// Print it as JSON.
-	data, err := json.MarshalIndent(place, "", " ")
+	// encoding/json marshals map keys in sorted order, so the
+	// places are printed sorted by name.
+	data, err := marshalStable(places)
 	if err != nil {
 		log.Fatalf("marshaling places: %s", err)
 	}
@@ -194,32 +173,17 @@ func placeByName(name string) (Location, error) {
 }
 
 func writePlaces(m map[string]Location) error {
-	home := HOME()
 	if m == nil {
 		m = map[string]Location{} // so that it marshals to: {}
 	}
-	contents, err := json.MarshalIndent(m, "", "  ")
-	if err != nil {
-		return err
-	}
-	return ioutil.WriteFile(filepath.Join(home, rootDir, placesFile), contents, permFile)
+	return writeStored(placesFile, m)
 }
 
 // readPlaces returns the existing places or an empty, non-nil map
 // if no places exist yet.
 func readPlaces() (map[string]Location, error) {
-	home := HOME()
-
-	b, err := ioutil.ReadFile(filepath.Join(home, rootDir, placesFile))
-	if err != nil {
-		if os.IsNotExist(err) {
-			return map[string]Location{}, nil
-		}
-		return nil, err
-	}
 	var places map[string]Location
-	err = json.Unmarshal(b, &places)
-	if err != nil {
+	if _, err := readStored(placesFile, &places); err != nil {
 		return nil, err
 	}
 	if places == nil {
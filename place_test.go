@@ -0,0 +1,32 @@
+package main
+
+import "testing"
+
+func TestPlaceByName(t *testing.T) {
+	tempHome(t)
+	const want = `place "home" not found`
+
+	// No places file yet.
+	if _, err := placeByName("home"); err == nil || err.Error() != want {
+		t.Errorf("no places file: got error %v, want %q", err, want)
+	}
+
+	// No places saved.
+	if err := writePlaces(nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := placeByName("home"); err == nil || err.Error() != want {
+		t.Errorf("no places: got error %v, want %q", err, want)
+	}
+
+	work := Location{Lat: 37.7849, Lng: -122.4094}
+	if err := writePlaces(map[string]Location{"work": work}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := placeByName("home"); err == nil || err.Error() != want {
+		t.Errorf("other places: got error %v, want %q", err, want)
+	}
+	if got, err := placeByName("work"); err != nil || got != work {
+		t.Errorf("saved place: got (%+v, %v), want (%+v, nil)", got, err, work)
+	}
+}
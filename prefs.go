@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// Prefs is the user's saved default preferences. They are used for the
+// corresponding flags when the flags aren't explicitly set.
+type Prefs struct {
+	Type    string // Value for the -type flag.
+	Notify  bool   // Value for the -notify flag.
+	Watch   bool   // Value for the -watch flag.
+	History bool   // Whether to save completed rides to the local history.
+	Region  string // Appended to street addresses that aren't found, e.g. "San Francisco, CA".
+}
+
+// apply updates f with the preferences for flags that weren't
+// explicitly set on the command line. The set map holds the names of
+// the explicitly set flags.
+func (p Prefs) apply(f *Flags, set map[string]bool) {
+	if !set["type"] && p.Type != "" {
+		f.car = p.Type
+	}
+	if !set["notify"] && p.Notify {
+		f.notifications = true
+		f.watch = true
+	}
+	if !set["watch"] && p.Watch {
+		f.watch = true
+	}
+	f.history = p.History
+	f.region = p.Region
+}
+
+func cmdPrefs(args []string) {
+	if len(args) == 0 {
+		usage()
+	}
+
+	switch args[0] {
+	case "set":
+		cmdPrefsSet(args[1:])
+	case "show":
+		cmdPrefsShow(args[1:])
+	default:
+		usage()
+	}
+}
+
+func cmdPrefsSet(args []string) {
+	if len(args) != 2 {
+		log.Fatalf("must specify a <key> and <value> to set")
+	}
+	key, value := args[0], args[1]
+
+	// A malformed prefs file is replaced, rather than making it
+	// impossible to fix the prefs using this command.
+	prefs, err := readPrefs()
+	if err != nil {
+		log.Printf("warning: ignoring existing prefs: %s", err)
+		prefs = Prefs{}
+	}
+
+	switch key {
+	case "type":
+		if flagToRideType(value) == "" {
+			log.Fatalf("unknown ride type %q", value)
+		}
+		prefs.Type = value
+	case "notify", "watch", "history":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			log.Fatalf("invalid value %q for %s; must be true or false", value, key)
+		}
+		switch key {
+		case "notify":
+			prefs.Notify = b
+		case "watch":
+			prefs.Watch = b
+		case "history":
+			prefs.History = b
+		}
+	case "region":
+		prefs.Region = value
+	default:
+		log.Fatalf("unknown pref %q; must be one of: type, notify, watch, history, region", key)
+	}
+
+	if err := writePrefs(prefs); err != nil {
+		log.Fatalf("saving prefs: %s", err)
+	}
+	os.Exit(0)
+}
+
+func cmdPrefsShow(args []string) {
+	prefs, err := readPrefs()
+	if err != nil {
+		log.Fatalf("reading prefs: %s\n%s", err, prefsHint)
+	}
+	data, err := json.MarshalIndent(prefs, "", " ")
+	if err != nil {
+		log.Fatalf("marshaling prefs: %s", err)
+	}
+	fmt.Fprintf(os.Stdout, "%s\n", data)
+	os.Exit(0)
+}
+
+// prefsHint is printed when the prefs file can't be read.
+const prefsHint = "fix or remove the file, or run 'lyft prefs set <key> <value>' to replace it"
+
+// readPrefs returns the saved preferences, or the zero Prefs if
+// none have been saved yet. The returned error names the prefs file.
+func readPrefs() (Prefs, error) {
+	var p Prefs
+	if _, err := readStored(prefsFile, &p); err != nil {
+		return Prefs{}, fmt.Errorf("%s: %s", filepath.Join(HOME(), rootDir, prefsFile), err)
+	}
+	return p, nil
+}
+
+func writePrefs(p Prefs) error {
+	return writeStored(prefsFile, p)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPrefsApply(t *testing.T) {
+	defaults := Flags{car: "line"}
+	prefs := Prefs{Type: "lyft", Notify: true, History: true, Region: "San Francisco, CA"}
+	if flagToRideType(prefs.Type) == "" {
+		t.Fatalf("invalid ride type %q in prefs", prefs.Type)
+	}
+
+	// Without explicitly set flags, the prefs override the defaults.
+	f := defaults
+	prefs.apply(&f, map[string]bool{})
+	if f.car != "lyft" || !f.notifications || !f.watch || !f.history || f.region != "San Francisco, CA" {
+		t.Errorf("prefs not applied: %+v", f)
+	}
+
+	// Explicitly set flags override the prefs.
+	f = defaults
+	f.car = "premier"
+	prefs.apply(&f, map[string]bool{"type": true, "notify": true})
+	if f.car != "premier" {
+		t.Errorf("car: got %q, want %q", f.car, "premier")
+	}
+	if f.notifications || f.watch {
+		t.Errorf("notify: got notifications=%t watch=%t, want false", f.notifications, f.watch)
+	}
+
+	// Unset prefs leave the defaults.
+	f = defaults
+	Prefs{}.apply(&f, map[string]bool{})
+	if f.car != "line" || f.notifications || f.watch || f.history || f.region != "" {
+		t.Errorf("defaults changed: %+v", f)
+	}
+
+	// The watch pref applies unless -watch is set.
+	f = defaults
+	Prefs{Watch: true}.apply(&f, map[string]bool{})
+	if !f.watch {
+		t.Error("watch pref not applied")
+	}
+	f = defaults
+	Prefs{Watch: true}.apply(&f, map[string]bool{"watch": true})
+	if f.watch {
+		t.Error("watch pref applied despite -watch being set")
+	}
+}
+
+func TestReadPrefsMalformed(t *testing.T) {
+	home := tempHome(t)
+	dir := filepath.Join(home, rootDir)
+	if err := os.MkdirAll(dir, permRootDir); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, prefsFile)
+	if err := ioutil.WriteFile(path, []byte("{not json"), permFile); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := readPrefs()
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if !strings.Contains(err.Error(), path) {
+		t.Errorf("error %q doesn't name %s", err, path)
+	}
+}
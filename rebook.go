@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/nishanths/lyft-go"
+	"github.com/nishanths/lyft-go/auth"
+)
+
+func cmdRideRebook(args []string, flags Flags) {
+	if len(args) == 0 {
+		log.Fatalf("must specify a <ride-id> to rebook")
+	}
+
+	inter := getInternal(flags)
+	if err := requireScope(inter, auth.RidesRequest); err != nil {
+		log.Fatal(err)
+	}
+	lyftClient := newLyftClient(inter)
+
+	var detail lyft.RideDetail
+	var h http.Header
+	err := withRefresh(lyftClient, &inter, func() (err error) {
+		detail, h, err = lyftClient.RideDetail(args[0])
+		return err
+	})
+	if err != nil {
+		log.Fatalf("fetching ride details: %s", describeError(err, h, inter.Sandbox))
+	}
+
+	req := rebookRequest(detail)
+	if req.Destination == (lyft.Location{}) {
+		// The original ride had no end location; ask for one.
+		prompt := "Enter end location (street address or lat,lng; can be empty): "
+		if req.RideType == lyft.RideTypeLine {
+			prompt = "Enter end location: "
+		}
+		end, err := endLocation(Flags{region: flags.region}, prompt)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if end != nil {
+			req.Destination = lyft.Location{Latitude: end.Lat, Longitude: end.Lng, Address: end.Address}
+		}
+	}
+
+	start := Location{req.Origin.Latitude, req.Origin.Longitude, req.Origin.Address}
+	var end *Location
+	if req.Destination != (lyft.Location{}) {
+		end = &Location{req.Destination.Latitude, req.Destination.Longitude, req.Destination.Address}
+	}
+	fmt.Fprintf(os.Stdout, "Ride Type: %s\n", lyft.RideTypeDisplay(req.RideType))
+	printRoute(&start, end)
+	fmt.Fprintln(os.Stdout)
+
+	if flags.dryRun {
+		os.Exit(0)
+	}
+	if parseNo(interactiveInput("Request this ride? [y/N]: ")) {
+		fmt.Fprintf(os.Stdout, "Not making any changes.\n")
+		os.Exit(0)
+	}
+
+	submitRide(lyftClient, inter, req, flags)
+}
+
+// rebookRequest returns a request for a new ride with the same start
+// location, end location, and ride type as the supplied ride. The
+// request's Destination is the zero Location if the ride had no end
+// location.
+func rebookRequest(detail lyft.RideDetail) lyft.RideRequest {
+	orig, dest := detail.Origin, detail.Destination
+	req := lyft.RideRequest{
+		Origin:   lyft.Location{Latitude: orig.Latitude, Longitude: orig.Longitude, Address: orig.Address},
+		RideType: detail.RideType,
+	}
+	if dest.Latitude != 0 || dest.Longitude != 0 {
+		req.Destination = lyft.Location{Latitude: dest.Latitude, Longitude: dest.Longitude, Address: dest.Address}
+	}
+	return req
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nishanths/lyft-go"
+)
+
+func TestRebookRequest(t *testing.T) {
+	detail := lyft.RideDetail{
+		RideID:      "123",
+		RideType:    lyft.RideTypeLine,
+		Origin:      lyft.RideLocation{Latitude: 37.7749, Longitude: -122.4194, Address: "1 Market St", ETA: time.Minute},
+		Destination: lyft.RideLocation{Latitude: 37.7609, Longitude: -122.4350, Address: "500 Castro St"},
+		Pickup:      lyft.RideLocation{Latitude: 37.7750, Longitude: -122.4195},
+	}
+	req := rebookRequest(detail)
+	want := lyft.RideRequest{
+		Origin:      lyft.Location{Latitude: 37.7749, Longitude: -122.4194, Address: "1 Market St"},
+		Destination: lyft.Location{Latitude: 37.7609, Longitude: -122.4350, Address: "500 Castro St"},
+		RideType:    lyft.RideTypeLine,
+	}
+	if req != want {
+		t.Errorf("got %+v, want %+v", req, want)
+	}
+
+	// Without a destination.
+	detail.Destination = lyft.RideLocation{}
+	req = rebookRequest(detail)
+	if req.Destination != (lyft.Location{}) {
+		t.Errorf("got destination %+v, want none", req.Destination)
+	}
+	if req.Origin != want.Origin || req.RideType != want.RideType {
+		t.Errorf("got %+v, want origin %+v and ride type %s", req, want.Origin, want.RideType)
+	}
+}
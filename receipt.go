@@ -0,0 +1,190 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/nishanths/lyft-go"
+	"github.com/nishanths/lyft-go/auth"
+)
+
+const receiptTimeLayout = "Mon Jan 2, 2006 3:04 PM"
+
+// How often, and for how long, to check for the receipt with -wait.
+const (
+	receiptWaitInterval = 15 * time.Second
+	receiptWaitTimeout  = 10 * time.Minute
+)
+
+func cmdRideReceipt(args []string, flags Flags) {
+	fs := flag.NewFlagSet("receipt", flag.ExitOnError)
+	full := fs.Bool("full", false, "")
+	wait := fs.Bool("wait", false, "")
+	fs.Usage = usage
+	rideID := parseArgs(fs, args)
+	if rideID == "" {
+		log.Fatalf("must specify a <ride-id> for the receipt")
+	}
+
+	inter := getInternal(flags)
+	if err := requireScope(inter, auth.RidesRead); err != nil {
+		log.Fatal(err)
+	}
+	lyftClient := newLyftClient(inter)
+
+	var receipt lyft.RideReceipt
+	var h http.Header
+	fetch := func() error {
+		return withRefresh(lyftClient, &inter, func() (err error) {
+			receipt, h, err = lyftClient.RideReceipt(rideID)
+			return err
+		})
+	}
+	var err error
+	if *wait {
+		err = waitForReceipt(fetch, time.Sleep, time.Now, receiptWaitTimeout)
+	} else {
+		err = fetch()
+	}
+	if receiptNotReady(err) {
+		if *wait {
+			log.Fatalf("receipt for ride %s still isn't available; check the ride ID, or try again later", rideID)
+		}
+		log.Fatalf("receipt for ride %s isn't available; if the ride was just dropped off, try again in a few minutes or use -wait", rideID)
+	}
+	if err != nil {
+		log.Fatalf("fetching receipt: %s", describeError(err, h, inter.Sandbox))
+	}
+
+	w := standardTabWriter()
+	if *full {
+		var detail lyft.RideDetail
+		err := withRefresh(lyftClient, &inter, func() (err error) {
+			detail, h, err = lyftClient.RideDetail(rideID)
+			return err
+		})
+		if err != nil {
+			log.Fatalf("fetching ride details: %s", describeError(err, h, inter.Sandbox))
+		}
+		printRideSummary(w, detail)
+		fmt.Fprintln(w)
+	} else {
+		fmt.Fprintf(w, "Ride ID:\t%s\n", receipt.RideID)
+		if !receipt.Requested.IsZero() {
+			fmt.Fprintf(w, "Requested:\t%s\n", receipt.Requested.Local().Format(receiptTimeLayout))
+		}
+	}
+	printReceipt(w, receipt)
+	w.Flush()
+	os.Exit(0)
+}
+
+// waitForReceipt calls fetch until it returns an error other than the
+// error for a receipt that isn't available yet, or until timeout elapses,
+// and returns the last error. The receipt is usually not found for a
+// short while after the ride is dropped off. sleep and now are
+// time.Sleep and time.Now, except in tests.
+func waitForReceipt(fetch func() error, sleep func(time.Duration), now func() time.Time, timeout time.Duration) error {
+	deadline := now().Add(timeout)
+	err := fetch()
+	for receiptNotReady(err) && now().Before(deadline) {
+		sleep(watchJitter.apply(receiptWaitInterval))
+		err = fetch()
+	}
+	return err
+}
+
+// receiptNotReady reports whether err is the error returned for a
+// receipt that isn't available yet.
+func receiptNotReady(err error) bool {
+	se, ok := err.(*lyft.StatusError)
+	return ok && se.StatusCode == http.StatusNotFound
+}
+
+// printRideSummary prints the details of a completed ride that are
+// relevant to a receipt: the date, route, driver, and vehicle.
+func printRideSummary(w io.Writer, detail lyft.RideDetail) {
+	fmt.Fprintf(w, "Ride ID:\t%s\n", detail.RideID)
+	fmt.Fprintf(w, "Ride Type:\t%s\n", lyft.RideTypeDisplay(detail.RideType))
+	if !detail.Requested.IsZero() {
+		fmt.Fprintf(w, "Requested:\t%s\n", detail.Requested.Local().Format(receiptTimeLayout))
+	}
+	if detail.RideProfile != "" {
+		fmt.Fprintf(w, "Profile:\t%s\n", strings.Title(detail.RideProfile))
+	}
+	printTimeline(w, detail)
+	if detail.Distance != 0 {
+		fmt.Fprintf(w, "Distance:\t%.1f mi\n", detail.Distance)
+	}
+	if detail.Duration != 0 {
+		fmt.Fprintf(w, "Duration:\t%s\n", detail.Duration)
+	}
+	if detail.Driver.FirstName != "" {
+		fmt.Fprintf(w, "Driver:\t%s %s\n", detail.Driver.FirstName, detail.Driver.LastName)
+	}
+	if v := detail.Vehicle; v.Make != "" {
+		fmt.Fprintf(w, "Vehicle:\t%s %s %s (%s)\n", v.Color, v.Make, v.Model, v.LicensePlate)
+	}
+}
+
+// printTimeline prints the pickup and dropoff times and addresses of
+// the ride, falling back to the requested origin and destination
+// addresses if the actual ones aren't available.
+func printTimeline(w io.Writer, detail lyft.RideDetail) {
+	line := func(label string, actual, requested lyft.RideLocation) {
+		addr := actual.Address
+		if addr == "" {
+			addr = requested.Address
+		}
+		if actual.Time.IsZero() {
+			fmt.Fprintf(w, "%s:\t%s\n", label, addr)
+			return
+		}
+		fmt.Fprintf(w, "%s:\t%s\t%s\n", label, actual.Time.Local().Format("3:04 PM"), addr)
+	}
+	line("Pickup", detail.Pickup, detail.Origin)
+	line("Dropoff", detail.Dropoff, detail.Destination)
+}
+
+// printReceipt prints the total price, line items, and charges in the
+// receipt.
+func printReceipt(w io.Writer, receipt lyft.RideReceipt) {
+	fmt.Fprintf(w, "Total:\t%s\n", formatAmount(receipt.Price.Amount, receipt.Price.Currency))
+	if li, ok := surgeLineItem(receipt.LineItems); ok {
+		fmt.Fprintf(w, "Primetime:\t%s of the total was primetime pricing\n", formatAmount(li.Amount, li.Currency))
+	}
+	for i, li := range receipt.LineItems {
+		label := ""
+		if i == 0 {
+			label = "Line items:"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", label, li.Description, formatAmount(li.Amount, li.Currency))
+	}
+	for i, c := range receipt.Charges {
+		label := ""
+		if i == 0 {
+			label = "Charges:"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", label, c.PaymentMethod, formatAmount(c.Amount, c.Currency))
+	}
+}
+
+// surgeLineItem returns the line item for primetime (surge) pricing, and
+// whether one was found.
+func surgeLineItem(items []lyft.LineItem) (lyft.LineItem, bool) {
+	for _, li := range items {
+		// The line item's type isn't documented as a fixed set of values,
+		// so match loosely.
+		t := strings.ToLower(li.Description)
+		if strings.Contains(t, "primetime") || strings.Contains(t, "prime time") || strings.Contains(t, "surge") {
+			return li, true
+		}
+	}
+	return lyft.LineItem{}, false
+}
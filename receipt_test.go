@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"testing"
+	"time"
+
+	"github.com/nishanths/lyft-go"
+)
+
+func TestPrintRideSummary(t *testing.T) {
+	detail := lyft.RideDetail{
+		RideID:      "123",
+		RideType:    lyft.RideTypeLyft,
+		Requested:   time.Date(2018, 11, 23, 14, 5, 0, 0, time.Local),
+		RideProfile: "business",
+		Origin:      lyft.RideLocation{Address: "1 Market St"},
+		Pickup:      lyft.RideLocation{Time: time.Date(2018, 11, 23, 14, 12, 0, 0, time.Local)},
+		Dropoff:     lyft.RideLocation{Address: "500 Castro St", Time: time.Date(2018, 11, 23, 14, 40, 0, 0, time.Local)},
+		Distance:    4.25,
+		Duration:    28 * time.Minute,
+		Driver:      lyft.Person{FirstName: "Sam", LastName: "Lee"},
+		Vehicle:     lyft.Vehicle{Color: "Blue", Make: "Toyota", Model: "Prius", LicensePlate: "7ABC123"},
+	}
+
+	var buf bytes.Buffer
+	printRideSummary(&buf, detail)
+	want := "Ride ID:\t123\n" +
+		"Ride Type:\tLyft\n" +
+		"Requested:\tFri Nov 23, 2018 2:05 PM\n" +
+		"Profile:\tBusiness\n" +
+		"Pickup:\t2:12 PM\t1 Market St\n" +
+		"Dropoff:\t2:40 PM\t500 Castro St\n" +
+		"Distance:\t4.2 mi\n" +
+		"Duration:\t28m0s\n" +
+		"Driver:\tSam Lee\n" +
+		"Vehicle:\tBlue Toyota Prius (7ABC123)\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestPrintReceipt(t *testing.T) {
+	receipt := lyft.RideReceipt{
+		Price: lyft.Price{Amount: 2350, Currency: "USD"},
+		LineItems: []lyft.LineItem{
+			{Amount: 1850, Currency: "USD", Description: "ride_fare"},
+			{Amount: 500, Currency: "USD", Description: "primetime"},
+		},
+		Charges: []lyft.Charge{
+			{Amount: 2350, Currency: "USD", PaymentMethod: "card"},
+		},
+	}
+
+	var buf bytes.Buffer
+	printReceipt(&buf, receipt)
+	want := "Total:\t$23.50\n" +
+		"Primetime:\t$5.00 of the total was primetime pricing\n" +
+		"Line items:\tride_fare\t$18.50\n" +
+		"\tprimetime\t$5.00\n" +
+		"Charges:\tcard\t$23.50\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestParseArgs(t *testing.T) {
+	tests := []struct {
+		args []string
+		arg  string
+		full bool
+	}{
+		{[]string{"123"}, "123", false},
+		{[]string{"123", "-full"}, "123", true},
+		{[]string{"-full", "123"}, "123", true},
+		{[]string{"-full"}, "", true},
+		{nil, "", false},
+	}
+	for _, tt := range tests {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		full := fs.Bool("full", false, "")
+		if got := parseArgs(fs, tt.args); got != tt.arg {
+			t.Errorf("%v: got arg %q, want %q", tt.args, got, tt.arg)
+		}
+		if *full != tt.full {
+			t.Errorf("%v: got full=%t, want %t", tt.args, *full, tt.full)
+		}
+	}
+}
+
+func TestSurgeLineItem(t *testing.T) {
+	tests := []struct {
+		items []lyft.LineItem
+		want  string // description of the surge line item; empty for none
+	}{
+		{nil, ""},
+		{[]lyft.LineItem{{Amount: 1850, Description: "ride_fare"}, {Amount: 150, Description: "service_fee"}}, ""},
+		{[]lyft.LineItem{{Amount: 1850, Description: "ride_fare"}, {Amount: 500, Description: "primetime"}}, "primetime"},
+		{[]lyft.LineItem{{Amount: 500, Description: "Prime Time"}}, "Prime Time"},
+		{[]lyft.LineItem{{Amount: 1850, Description: "ride_fare"}, {Amount: 300, Description: "surge_charge"}}, "surge_charge"},
+	}
+	for _, tt := range tests {
+		li, ok := surgeLineItem(tt.items)
+		if ok != (tt.want != "") {
+			t.Errorf("%v: got ok=%t", tt.items, ok)
+			continue
+		}
+		if li.Description != tt.want {
+			t.Errorf("%v: got %q, want %q", tt.items, li.Description, tt.want)
+		}
+	}
+}
+
+// fakeClock is a clock that only advances when sleeping.
+type fakeClock struct {
+	t      time.Time
+	sleeps []time.Duration
+}
+
+func (c *fakeClock) now() time.Time { return c.t }
+
+func (c *fakeClock) sleep(d time.Duration) {
+	c.sleeps = append(c.sleeps, d)
+	c.t = c.t.Add(d)
+}
+
+func TestWaitForReceipt(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2018, 11, 23, 15, 0, 0, 0, time.UTC)}
+	calls := 0
+	fetch := func() error {
+		calls++
+		if calls == 1 {
+			return &lyft.StatusError{StatusCode: 404}
+		}
+		return nil
+	}
+	if err := waitForReceipt(fetch, clock.sleep, clock.now, receiptWaitTimeout); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 2 {
+		t.Errorf("got %d calls, want 2", calls)
+	}
+	if len(clock.sleeps) != 1 {
+		t.Fatalf("got %d sleeps, want 1", len(clock.sleeps))
+	}
+	if d := clock.sleeps[0]; d < receiptWaitInterval*8/10 || d > receiptWaitInterval*12/10 {
+		t.Errorf("slept %s, want about %s", d, receiptWaitInterval)
+	}
+}
+
+func TestWaitForReceiptTimeout(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2018, 11, 23, 15, 0, 0, 0, time.UTC)}
+	start := clock.t
+	notFound := &lyft.StatusError{StatusCode: 404}
+	err := waitForReceipt(func() error { return notFound }, clock.sleep, clock.now, receiptWaitTimeout)
+	if err != notFound {
+		t.Errorf("got error %v, want the not found error", err)
+	}
+	if waited := clock.t.Sub(start); waited < receiptWaitTimeout || waited > receiptWaitTimeout+2*receiptWaitInterval {
+		t.Errorf("waited %s, want about %s", waited, receiptWaitTimeout)
+	}
+
+	// Other errors aren't retried.
+	calls := 0
+	other := &lyft.StatusError{StatusCode: 500}
+	err = waitForReceipt(func() error { calls++; return other }, clock.sleep, clock.now, receiptWaitTimeout)
+	if err != other || calls != 1 {
+		t.Errorf("got (%v, %d calls), want (%v, 1 call)", err, calls, other)
+	}
+}
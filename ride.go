@@ -1,14 +1,17 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"net/http"
 	"os"
 	"strings"
 	"time"
 
 	"github.com/nishanths/lyft-go"
+	"github.com/nishanths/lyft-go/auth"
 )
 
 func cmdRide(args []string, flags Flags) {
@@ -23,52 +26,51 @@ func cmdRide(args []string, flags Flags) {
 		cmdRideCancel(args[1:], flags)
 	case "status":
 		cmdRideStatus(args[1:], flags)
+	case "receipt":
+		cmdRideReceipt(args[1:], flags)
+	case "ics":
+		cmdRideICS(args[1:], flags)
+	case "rebook":
+		cmdRideRebook(args[1:], flags)
+	case "update":
+		cmdRideUpdate(args[1:], flags)
+	case "list":
+		cmdRideList(args[1:], flags)
 	default:
 		usage()
 	}
 }
 
 func cmdRideCreate(args []string, flags Flags) {
-	inter := getInternal()
-	lyftClient := lyft.NewClient(inter.AccessToken)
-
-	var start, end *Location
+	inter := getInternal(flags)
+	if err := requireScope(inter, auth.RidesRequest); err != nil {
+		log.Fatal(err)
+	}
+	lyftClient := newLyftClient(inter)
 
-	if flags.startPlace != "" {
-		loc, err := placeByName(flags.startPlace)
-		if err != nil {
-			log.Fatalf("place %q not found", flags.startPlace)
-		}
-		start = &loc
+	start, err := startLocation(flags)
+	if err != nil {
+		log.Fatal(err)
 	}
-	if flags.endPlace != "" {
-		loc, err := placeByName(flags.endPlace)
-		if err != nil {
-			log.Fatalf("place %q not found", flags.endPlace)
-		}
-		end = &loc
+	prompt := "Enter end location (street address or lat,lng; can be empty): "
+	if flags.rideType() == lyft.RideTypeLine {
+		prompt = "Enter end location: "
+	}
+	end, err := endLocation(flags, prompt)
+	if err != nil {
+		log.Fatal(err)
 	}
 
-	if start == nil {
-		loc, err := parseLocationInput(interactiveInput("Enter start location (street address or lat,lng): "), mapsClient)
+	// Catch accidentally entering the same start and end locations.
+	for end != nil && distance(*start, *end) < sameLocationThreshold {
+		input := interactiveInput(fmt.Sprintf("Start and end locations are within %dm of each other. Continue anyway? [y/N]: ", sameLocationThreshold))
+		if !parseNo(input) {
+			break
+		}
+		end, err = endLocation(Flags{region: flags.region}, prompt) // prompt again, even if -to was specified
 		if err != nil {
 			log.Fatal(err)
 		}
-		start = &loc
-	}
-	if end == nil {
-		prompt := "Enter end location (street address or lat,lng; can be empty): "
-		if flags.rideType() == lyft.RideTypeLine {
-			prompt = "Enter end location: "
-		}
-		str := interactiveInput(prompt)
-		if str != "" {
-			loc, err := parseLocationInput(str, mapsClient)
-			if err != nil {
-				log.Fatal(err)
-			}
-			end = &loc
-		}
 	}
 
 	printRoute(start, end)
@@ -86,44 +88,115 @@ func cmdRideCreate(args []string, flags Flags) {
 		os.Exit(0)
 	}
 
-	created, _, err := lyftClient.RequestRide(req)
+	submitRide(lyftClient, inter, req, flags)
+}
+
+// submitRide requests the ride, asking for confirmation of the cost if
+// necessary, and prints the created ride's ID. If the -watch flag is set,
+// it then watches the ride's status.
+func submitRide(lyftClient *lyft.Client, inter Internal, req lyft.RideRequest, flags Flags) {
+	created, h, err := requestRide(lyftClient, inter, req, func(c lyft.CostTokenInfo) bool {
+		input := interactiveInput(fmt.Sprintf("Primetime pricing (%s) is in effect. Continue? [y/N]: ", c.PrimetimePercentage))
+		return !parseNo(input)
+	}, time.Now)
+	if err == errCostDeclined {
+		fmt.Fprintf(os.Stdout, "Not making any changes.\n")
+		os.Exit(0)
+	}
 	if err != nil {
-		if lyft.IsTokenExpired(err) {
-			lyftClient.SetAccessToken(refreshAndWriteToken(inter))
-			created, _, err = lyftClient.RequestRide(req)
-		}
-		if err != nil { // still an error?
-			log.Fatalf("creating ride: %s", err)
-		}
+		log.Fatalf("creating ride: %s", describeError(err, h, inter.Sandbox))
 	}
 	fmt.Fprintf(os.Stdout, "Created Ride ID: %s\n", created.RideID)
 	fmt.Fprintf(os.Stdout, "Cancel the ride: lyft ride cancel %s\n", created.RideID)
 
 	if flags.watch {
-		rideStatus(created.RideID, flags.watch, flags.notifications)
+		rideStatus(created.RideID, flags)
 	} else {
 		fmt.Fprintf(os.Stdout, "Watch ride status: lyft -watch ride status %s\n", created.RideID)
 		os.Exit(0)
 	}
 }
 
+// errCostDeclined is returned by requestRide if the cost
+// wasn't confirmed.
+var errCostDeclined = errors.New("cost not confirmed")
+
+// requestRide requests a ride. If the cost must be confirmed first (for
+// instance, because of primetime pricing), confirm is called with the cost
+// details and the ride is requested again with the cost token if confirm
+// returns true. If confirm returns false, the error is errCostDeclined.
+//
+// If the cost token expires before confirm returns, the cost is requested
+// again and confirm is called with the new details, since submitting an
+// expired token fails. now returns the current time.
+func requestRide(lyftClient *lyft.Client, inter Internal, req lyft.RideRequest, confirm func(lyft.CostTokenInfo) bool, now func() time.Time) (lyft.CreatedRide, http.Header, error) {
+	var created lyft.CreatedRide
+	var h http.Header
+	request := func() (err error) {
+		created, h, err = lyftClient.RequestRide(req)
+		return err
+	}
+
+	err := withRefresh(lyftClient, &inter, request)
+	for {
+		rre, ok := err.(*lyft.RideRequestError)
+		if !ok || rre.Cost == nil || rre.Cost.CostToken == "" {
+			break
+		}
+		issued := now()
+		if !confirm(*rre.Cost) {
+			return lyft.CreatedRide{}, h, errCostDeclined
+		}
+		if !costTokenValid(issued, rre.Cost.TokenDuration, now()) {
+			fmt.Fprintf(os.Stdout, "The price confirmation expired. Checking the price again.\n")
+			req.CostToken = ""
+			err = withRefresh(lyftClient, &inter, request)
+			continue
+		}
+		req.CostToken = rre.Cost.CostToken
+		err = withRefresh(lyftClient, &inter, request)
+		break
+	}
+	return created, h, err
+}
+
+// costTokenMargin is subtracted from a cost token's lifetime, so that a
+// token that is about to expire isn't submitted.
+const costTokenMargin = 5 * time.Second
+
+// costTokenValid reports whether a cost token received at issued with
+// lifetime d can still be used at now. A token with an unknown (zero)
+// lifetime is assumed to be valid.
+func costTokenValid(issued time.Time, d time.Duration, now time.Time) bool {
+	if d == 0 {
+		return true
+	}
+	return now.Before(issued.Add(d - costTokenMargin))
+}
+
 func cmdRideCancel(args []string, flags Flags) {
 	if len(args) == 0 {
 		log.Fatalf("must specify a <ride-id> to cancel")
 	}
 
-	inter := getInternal()
-	lyftClient := lyft.NewClient(inter.AccessToken)
+	inter := getInternal(flags)
+	if err := requireScope(inter, auth.RidesRequest); err != nil {
+		log.Fatal(err)
+	}
+	lyftClient := newLyftClient(inter)
 
 	if flags.dryRun {
 		os.Exit(0)
 	}
 
 	var cancelToken string
-	var expireRetry bool
+	var h http.Header
 
 cancel:
-	_, err := lyftClient.CancelRide(args[0], cancelToken)
+	err := withRefresh(lyftClient, &inter, func() (err error) {
+		h, err = lyftClient.CancelRide(args[0], cancelToken)
+		return err
+	})
 	if err == nil {
 		os.Exit(0)
 	}
@@ -138,15 +211,7 @@ cancel:
 		os.Exit(0)
 	}
 
-	if expireRetry {
-		log.Fatalf("failed to cancel ride %s: %s", args[0], err)
-	}
-
-	if lyft.IsTokenExpired(err) {
-		lyftClient.SetAccessToken(refreshAndWriteToken(inter))
-		expireRetry = true
-		goto cancel
-	}
+	log.Fatalf("failed to cancel ride %s: %s", args[0], describeError(err, h, inter.Sandbox))
 }
 
 // Parses the string s as the value of a yes/no input.
@@ -177,111 +242,160 @@ func cmdRideStatus(args []string, flags Flags) {
 	if len(args) == 0 {
 		log.Fatalf("must specify a <ride-id> to check status")
 	}
-	rideStatus(args[0], flags.watch, flags.notifications)
+	rideStatus(args[0], flags)
 }
 
-func rideStatus(rideID string, watch, notifications bool) {
-	inter := getInternal()
-	lyftClient := lyft.NewClient(inter.AccessToken)
-
-	detail, _, err := lyftClient.RideDetail(rideID)
+func rideStatus(rideID string, flags Flags) {
+	inter := getInternal(flags)
+	if err := requireScope(inter, auth.RidesRead); err != nil {
+		log.Fatal(err)
+	}
+	lyftClient := newLyftClient(inter)
+
+	var h http.Header
+	fetch := func() (detail lyft.RideDetail, err error) {
+		err = withRefresh(lyftClient, &inter, func() (err error) {
+			detail, h, err = lyftClient.RideDetail(rideID)
+			return err
+		})
+		return detail, err
+	}
+	detail, err := fetch()
 	if err != nil {
-		if lyft.IsTokenExpired(err) {
-			lyftClient.SetAccessToken(refreshAndWriteToken(inter))
-			detail, _, err = lyftClient.RideDetail(rideID)
-		}
-		if err != nil { // still an error?
-			log.Fatalf("fetching ride status: %s", err)
-		}
+		log.Fatalf("fetching ride status: %s", describeError(err, h, inter.Sandbox))
 	}
 
-	loopSleep := 20 * time.Second
-	notified := make(map[string]bool)
-	notifyOnce := func(r, message, title, subtitle string) {
-		if notified[r] {
-			return
-		}
-		notified[r] = true
-		notify(message, title, subtitle)
-	}
+	notifyStatus := statusNotifier(notify)
 	w := standardTabWriter()
 
-	fmt.Fprintln(os.Stdout)
-	fmt.Fprintf(w, "Ride ID:\t%s\n", detail.RideID)
-	fmt.Fprintf(w, "Ride Type:\t%s\n", lyft.RideTypeDisplay(detail.RideType))
+	if flags.template == nil && !flags.json {
+		fmt.Fprintln(os.Stdout)
+		fmt.Fprintf(w, "Ride ID:\t%s\n", detail.RideID)
+		fmt.Fprintf(w, "Ride Type:\t%s\n", lyft.RideTypeDisplay(detail.RideType))
+	}
 
 	// None of this is expected to run into the rate limit.
-loop:
-	for {
+	err = watchRide(detail, flags.watch, fetch, time.Sleep, func(detail lyft.RideDetail) {
 		// Print status info.
-		fmt.Fprintf(w, "Status:\t%s\n", lyft.RideStatusDisplay(detail.RideStatus))
-		switch detail.RideStatus {
-		case lyft.StatusPending:
-			printPending(w, detail)
-		case lyft.StatusAccepted, lyft.StatusArrived:
-			printAcceptedArrived(w, detail)
-		case lyft.StatusCanceled:
-			printCanceled(w, detail)
-		}
-		w.Flush()
-		fmt.Fprintln(os.Stdout)
-
-		if notifications {
-			title := "Lyft Ride " + lyft.RideStatusDisplay(detail.RideStatus)
+		if flags.template != nil {
+			executeTemplate(flags.template, detail)
+		} else if flags.json {
+			writeJSON(newRideJSON(detail))
+		} else {
+			fmt.Fprintf(w, "Status:\t%s\n", lyft.RideStatusDisplay(detail.RideStatus))
 			switch detail.RideStatus {
+			case lyft.StatusPending:
+				printPending(w, detail)
+			case lyft.StatusAccepted, lyft.StatusArrived, lyft.StatusPickedUp:
+				printAcceptedArrived(w, detail)
 			case lyft.StatusCanceled:
-				message := "Ride ID " + detail.RideID + " has been canceled"
-				notifyOnce(detail.RideStatus, message, title, "")
-			case lyft.StatusAccepted:
-				message := "Ride ID " + detail.RideID + " has been accepted"
-				notifyOnce(detail.RideStatus, message, title, "")
-			case lyft.StatusArrived:
-				message := fmt.Sprintf("%s %s %s (%s)", detail.Vehicle.Color, detail.Vehicle.Make, detail.Vehicle.Model, detail.Vehicle.LicensePlate)
-				notifyOnce(detail.RideStatus, message, title, "")
+				printCanceled(w, detail)
 			}
+			w.Flush()
+			fmt.Fprintln(os.Stdout)
 		}
 
-		if watch {
-			// Set loop wait times/break.
-			switch detail.RideStatus {
-			case lyft.StatusPending:
-				// No change. Keep looping at same interval.
-			case lyft.StatusAccepted:
-				loopSleep = 10 * time.Second
-				if detail.Origin.ETA != 0 && detail.Origin.ETA < 120*time.Second {
-					loopSleep = 5 * time.Second
-				}
-			default:
-				break loop
-			}
-		} else {
-			break loop
+		if flags.notifications {
+			notifyStatus(detail)
 		}
 
-		time.Sleep(loopSleep)
-
-		// Update for next round.
-		detail, _, err = lyftClient.RideDetail(rideID)
-		if err != nil {
-			if lyft.IsTokenExpired(err) {
-				lyftClient.SetAccessToken(refreshAndWriteToken(inter))
-				detail, _, err = lyftClient.RideDetail(rideID)
-			}
-			if err != nil { // still an error?
-				log.Fatalf("fetching ride status: %s", err)
+		if flags.history && detail.RideStatus == lyft.StatusDroppedOff {
+			if err := recordRides(detail); err != nil {
+				log.Printf("saving ride %s to history: %s", detail.RideID, err)
 			}
 		}
+	})
+	if err != nil {
+		log.Fatalf("fetching ride status: %s", describeError(err, h, inter.Sandbox))
 	}
 
-	if watch {
+	if flags.watch && flags.template == nil && !flags.json {
 		fmt.Fprint(os.Stdout, "No more updates.\n")
-		var c chan struct{}
-		<-c // infinite wait
 	}
 
 	os.Exit(0)
 }
 
+// watchRide calls update with the ride's details. If watch is true, it
+// then keeps fetching the details, sleeping for the status's watch
+// interval in between, and calls update with each, until the status won't
+// change anymore. It returns the first error from fetch.
+func watchRide(detail lyft.RideDetail, watch bool, fetch func() (lyft.RideDetail, error), sleep func(time.Duration), update func(lyft.RideDetail)) error {
+	for {
+		update(detail)
+		if !watch {
+			return nil
+		}
+		interval, ok := watchInterval(detail)
+		if !ok {
+			return nil
+		}
+		sleep(watchJitter.apply(interval))
+
+		var err error
+		if detail, err = fetch(); err != nil {
+			return err
+		}
+	}
+}
+
+// statusNotifier returns a function that shows the notification, if any,
+// for a ride's status using notify. Each status is notified only once.
+func statusNotifier(notify func(message, title, subtitle string) error) func(lyft.RideDetail) {
+	notified := make(map[string]bool)
+	return func(detail lyft.RideDetail) {
+		if notified[detail.RideStatus] {
+			return
+		}
+		if message, title, ok := rideNotification(detail); ok {
+			notified[detail.RideStatus] = true
+			notify(message, title, "")
+		}
+	}
+}
+
+// rideNotification returns the desktop notification for the ride's
+// status, and false if there is none for the status.
+func rideNotification(detail lyft.RideDetail) (message, title string, ok bool) {
+	title = "Lyft Ride " + lyft.RideStatusDisplay(detail.RideStatus)
+	switch detail.RideStatus {
+	case lyft.StatusCanceled:
+		return "Ride ID " + detail.RideID + " has been canceled", title, true
+	case lyft.StatusAccepted:
+		return "Ride ID " + detail.RideID + " has been accepted", title, true
+	case lyft.StatusArrived:
+		v := detail.Vehicle
+		return fmt.Sprintf("%s %s %s (%s)", v.Color, v.Make, v.Model, v.LicensePlate), title, true
+	case lyft.StatusDroppedOff:
+		return "Ride ID " + detail.RideID + " is complete", "Lyft Trip Complete", true
+	}
+	return "", "", false
+}
+
+// watchInterval returns how long to wait before checking the ride's
+// status again when watching, and false if the status won't change
+// anymore.
+func watchInterval(detail lyft.RideDetail) (time.Duration, bool) {
+	switch detail.RideStatus {
+	case lyft.StatusPending:
+		return 20 * time.Second, true
+	case lyft.StatusAccepted:
+		if detail.Origin.ETA != 0 && detail.Origin.ETA < 120*time.Second {
+			return 5 * time.Second, true
+		}
+		return 10 * time.Second, true
+	case lyft.StatusArrived:
+		return 10 * time.Second, true
+	case lyft.StatusPickedUp:
+		if detail.Destination.ETA != 0 && detail.Destination.ETA < 120*time.Second {
+			return 10 * time.Second, true
+		}
+		return 30 * time.Second, true
+	}
+	// Dropped off, canceled, or unknown.
+	return 0, false
+}
+
 func printPending(w io.Writer, detail lyft.RideDetail) {
 	orig, dest := detail.Origin, detail.Destination
 	fmt.Fprintf(w, "Start:\t%s\n", googleMapsURL(orig.Latitude, orig.Longitude))
@@ -297,12 +411,13 @@ func printPending(w io.Writer, detail lyft.RideDetail) {
 func printAcceptedArrived(w io.Writer, detail lyft.RideDetail) {
 	orig, dest := detail.Origin, detail.Destination
 	fmt.Fprintf(w, "Start:\t%s\n", googleMapsURL(orig.Latitude, orig.Longitude))
+	now := time.Now()
 	if orig.Address != "" {
-		fmt.Fprintf(w, "\t%s (ETA=%s)\n", orig.Address, orig.ETA)
+		fmt.Fprintf(w, "\t%s (ETA=%s)\n", orig.Address, formatETA(orig.ETA, now))
 	}
 	fmt.Fprintf(w, "End:\t%s\n", googleMapsURL(dest.Latitude, dest.Longitude))
 	if dest.Address != "" {
-		fmt.Fprintf(w, "\t%s (ETA=%s)\n", dest.Address, dest.ETA)
+		fmt.Fprintf(w, "\t%s (ETA=%s)\n", dest.Address, formatETA(dest.ETA, now))
 	}
 	fmt.Fprintf(w, "Location:\t%s\n", googleMapsURL(detail.Location.Latitude, detail.Location.Longitude))
 	fmt.Fprintf(w, "Driver:\t%s %s, %s\n", detail.Driver.FirstName, detail.Driver.LastName, detail.Driver.Rating)
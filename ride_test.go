@@ -0,0 +1,239 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nishanths/lyft-go"
+)
+
+func TestWatchInterval(t *testing.T) {
+	tests := []struct {
+		detail lyft.RideDetail
+		want   time.Duration
+		ok     bool
+	}{
+		{lyft.RideDetail{RideStatus: lyft.StatusPending}, 20 * time.Second, true},
+		{lyft.RideDetail{RideStatus: lyft.StatusAccepted, Origin: lyft.RideLocation{ETA: 5 * time.Minute}}, 10 * time.Second, true},
+		{lyft.RideDetail{RideStatus: lyft.StatusAccepted, Origin: lyft.RideLocation{ETA: 90 * time.Second}}, 5 * time.Second, true},
+		{lyft.RideDetail{RideStatus: lyft.StatusAccepted}, 10 * time.Second, true},
+		{lyft.RideDetail{RideStatus: lyft.StatusArrived}, 10 * time.Second, true},
+		{lyft.RideDetail{RideStatus: lyft.StatusPickedUp, Destination: lyft.RideLocation{ETA: 10 * time.Minute}}, 30 * time.Second, true},
+		{lyft.RideDetail{RideStatus: lyft.StatusPickedUp, Destination: lyft.RideLocation{ETA: 119 * time.Second}}, 10 * time.Second, true},
+		{lyft.RideDetail{RideStatus: lyft.StatusDroppedOff}, 0, false},
+		{lyft.RideDetail{RideStatus: lyft.StatusCanceled}, 0, false},
+		{lyft.RideDetail{RideStatus: lyft.StatusUnknown}, 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := watchInterval(tt.detail)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("%s: got (%s, %t), want (%s, %t)", tt.detail.RideStatus, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+// TestWatchRide watches the mock server's ride from request to dropoff.
+func TestWatchRide(t *testing.T) {
+	c := newMockClient()
+	fetch := func() (lyft.RideDetail, error) {
+		detail, _, err := c.RideDetail(mockRideID)
+		return detail, err
+	}
+	var sleeps []time.Duration
+	sleep := func(d time.Duration) { sleeps = append(sleeps, d) }
+
+	var statuses, notifications []string
+	notifyStatus := statusNotifier(func(message, title, subtitle string) error {
+		notifications = append(notifications, title)
+		return nil
+	})
+
+	detail, err := fetch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = watchRide(detail, true, fetch, sleep, func(detail lyft.RideDetail) {
+		statuses = append(statuses, detail.RideStatus)
+		notifyStatus(detail)
+		notifyStatus(detail)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(statuses, mockStatuses) {
+		t.Errorf("got statuses %v, want %v", statuses, mockStatuses)
+	}
+	wantSleeps := []time.Duration{20 * time.Second, 5 * time.Second, 10 * time.Second, 30 * time.Second}
+	if len(sleeps) != len(wantSleeps) {
+		t.Fatalf("got sleeps %v, want about %v", sleeps, wantSleeps)
+	}
+	for i, d := range sleeps {
+		if d < wantSleeps[i]*8/10 || d > wantSleeps[i]*12/10 {
+			t.Errorf("sleep %d: got %s, want about %s", i, d, wantSleeps[i])
+		}
+	}
+	wantNotifications := []string{"Lyft Ride Accepted", "Lyft Ride Arrived", "Lyft Trip Complete"}
+	if !reflect.DeepEqual(notifications, wantNotifications) {
+		t.Errorf("got notifications %q, want %q", notifications, wantNotifications)
+	}
+}
+
+func TestWatchRideOnce(t *testing.T) {
+	fetch := func() (lyft.RideDetail, error) {
+		t.Fatal("fetched without -watch")
+		return lyft.RideDetail{}, nil
+	}
+	sleep := func(time.Duration) { t.Fatal("slept without -watch") }
+	n := 0
+	err := watchRide(lyft.RideDetail{RideStatus: lyft.StatusPending}, false, fetch, sleep, func(lyft.RideDetail) { n++ })
+	if err != nil || n != 1 {
+		t.Errorf("got (%d updates, %v), want (1, nil)", n, err)
+	}
+}
+
+func TestWatchRideFetchError(t *testing.T) {
+	want := errors.New("fetch failed")
+	fetch := func() (lyft.RideDetail, error) { return lyft.RideDetail{}, want }
+	err := watchRide(lyft.RideDetail{RideStatus: lyft.StatusPending}, true, fetch, func(time.Duration) {}, func(lyft.RideDetail) {})
+	if err != want {
+		t.Errorf("got %v, want %v", err, want)
+	}
+}
+
+func TestRideNotification(t *testing.T) {
+	detail := lyft.RideDetail{
+		RideID:     "1",
+		RideStatus: lyft.StatusArrived,
+		Vehicle:    lyft.Vehicle{Color: "Blue", Make: "Toyota", Model: "Prius", LicensePlate: "7ABC123"},
+	}
+	message, title, ok := rideNotification(detail)
+	if !ok || message != "Blue Toyota Prius (7ABC123)" || title != "Lyft Ride Arrived" {
+		t.Errorf("arrived: got (%q, %q, %t)", message, title, ok)
+	}
+
+	for _, s := range []string{lyft.StatusPending, lyft.StatusPickedUp} {
+		if _, _, ok := rideNotification(lyft.RideDetail{RideStatus: s}); ok {
+			t.Errorf("%s: got a notification, want none", s)
+		}
+	}
+}
+
+func TestCostTokenValid(t *testing.T) {
+	issued := time.Date(2018, 11, 23, 15, 0, 0, 0, time.UTC)
+	const d = time.Minute
+	limit := issued.Add(d - costTokenMargin)
+
+	tests := []struct {
+		d    time.Duration
+		now  time.Time
+		want bool
+	}{
+		{d, issued, true},
+		{d, limit.Add(-time.Nanosecond), true},
+		{d, limit, false},
+		{d, limit.Add(time.Second), false},
+		{d, issued.Add(d), false},
+		{0, issued, true},
+		{0, issued.Add(24 * time.Hour), true},
+	}
+	for _, tt := range tests {
+		if got := costTokenValid(issued, tt.d, tt.now); got != tt.want {
+			t.Errorf("d=%s now=issued+%s: got %t, want %t", tt.d, tt.now.Sub(issued), got, tt.want)
+		}
+	}
+}
+
+// primetimeServer starts a server that requires the cost of every ride
+// request to be confirmed with a cost token valid for 10 seconds. It
+// returns the server's URL and a function that returns the cost tokens of
+// the requests so far.
+func primetimeServer(t *testing.T) (string, func() []string) {
+	t.Helper()
+	var mu sync.Mutex
+	var tokens []string
+	issued := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/rides" || r.Method != "POST" {
+			http.NotFound(w, r)
+			return
+		}
+		var req lyft.RideRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		tokens = append(tokens, req.CostToken)
+		if req.CostToken == "" {
+			issued++
+			writeMockJSON(w, http.StatusBadRequest, fmt.Sprintf(`{"error": "primetime_confirmation_required", "primetime_percentage": "25%%", "cost_token": "cost-%d", "token_duration": "10"}`, issued))
+			return
+		}
+		writeMockJSON(w, http.StatusCreated, mockCreatedRide)
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), tokens...)
+	}
+}
+
+func TestRequestRideCostToken(t *testing.T) {
+	req := lyft.RideRequest{
+		Origin:      lyft.Location{Latitude: 37.7711, Longitude: -122.4317},
+		Destination: lyft.Location{Latitude: 37.7763, Longitude: -122.3918},
+		RideType:    lyft.RideTypeLyft,
+	}
+
+	tests := []struct {
+		name        string
+		confirmTime []time.Duration // Time taken to confirm each cost.
+		decline     bool
+		wantTokens  []string
+		wantErr     error
+	}{
+		{"confirmed", []time.Duration{time.Second}, false, []string{"", "cost-1"}, nil},
+		{"declined", []time.Duration{time.Second}, true, []string{""}, errCostDeclined},
+		{"expired", []time.Duration{time.Minute, time.Second}, false, []string{"", "", "cost-2"}, nil},
+	}
+	for _, tt := range tests {
+		url, tokens := primetimeServer(t)
+		inter := Internal{AccessToken: "token", noPersist: true, baseURL: url}
+		clock := &fakeClock{t: time.Date(2018, 11, 23, 15, 0, 0, 0, time.UTC)}
+
+		var confirms []lyft.CostTokenInfo
+		confirm := func(c lyft.CostTokenInfo) bool {
+			clock.sleep(tt.confirmTime[len(confirms)])
+			confirms = append(confirms, c)
+			return !tt.decline
+		}
+		created, _, err := requestRide(newLyftClient(inter), inter, req, confirm, clock.now)
+		if err != tt.wantErr {
+			t.Errorf("%s: got error %v, want %v", tt.name, err, tt.wantErr)
+		}
+		if tt.wantErr == nil && created.RideID != mockRideID {
+			t.Errorf("%s: got ride ID %q, want %q", tt.name, created.RideID, mockRideID)
+		}
+		if got := tokens(); !reflect.DeepEqual(got, tt.wantTokens) {
+			t.Errorf("%s: got requests with cost tokens %q, want %q", tt.name, got, tt.wantTokens)
+		}
+		if len(confirms) != len(tt.confirmTime) {
+			t.Errorf("%s: confirm called %d times, want %d", tt.name, len(confirms), len(tt.confirmTime))
+		}
+		for _, c := range confirms {
+			if c.PrimetimePercentage != "25%" || c.TokenDuration != 10*time.Second {
+				t.Errorf("%s: confirm called with %+v", tt.name, c)
+			}
+		}
+	}
+}
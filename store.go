@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// The files in the program's data directory (places, prefs, and the local
+// ride history) are read and written with readStored and writeStored, so
+// that they are all stored the same way.
+
+// readStored unmarshals the JSON file named file in the program's data
+// directory into v. It returns false, and a nil error, if the file
+// doesn't exist.
+func readStored(file string, v interface{}) (bool, error) {
+	b, err := ioutil.ReadFile(filepath.Join(HOME(), rootDir, file))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, json.Unmarshal(b, v)
+}
+
+// writeStored writes v as JSON to the file named file in the program's
+// data directory, creating the directory if necessary. The contents are
+// written to a temporary file that is then renamed, so that the file is
+// never left partially written.
+func writeStored(file string, v interface{}) error {
+	dir := filepath.Join(HOME(), rootDir)
+	if err := os.MkdirAll(dir, permRootDir); err != nil {
+		return err
+	}
+	contents, err := marshalStable(v)
+	if err != nil {
+		return err
+	}
+
+	f, err := ioutil.TempFile(dir, file+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name()) // no-op after a successful rename
+	if _, err := f.Write(contents); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Chmod(permFile); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), filepath.Join(dir, file))
+}
+
+// marshalStable returns the JSON encoding of v used for the files stored
+// in the program's data directory. The output is indented and stable
+// across writes of the same value: struct fields are encoded in
+// declaration order, and encoding/json encodes map keys in sorted order.
+func marshalStable(v interface{}) ([]byte, error) {
+	return json.MarshalIndent(v, "", "  ")
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestMarshalStable(t *testing.T) {
+	a := map[string]Location{}
+	b := map[string]Location{}
+	names := []string{"work", "home", "gym", "airport"}
+	for i, n := range names {
+		a[n] = Location{Lat: float64(i), Lng: -float64(i)}
+	}
+	for i := len(names) - 1; i >= 0; i-- {
+		b[names[i]] = Location{Lat: float64(i), Lng: -float64(i)}
+	}
+
+	first, err := marshalStable(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		got, err := marshalStable(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, first) {
+			t.Fatalf("output differs:\n%s\nwant:\n%s", got, first)
+		}
+	}
+
+	want := `{
+  "home": {
+    "Lat": 1,
+    "Lng": -1,
+    "Address": ""
+  },
+  "work": {
+    "Lat": 0,
+    "Lng": 0,
+    "Address": ""
+  }
+}`
+	got, err := marshalStable(map[string]Location{"work": {}, "home": {Lat: 1, Lng: -1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
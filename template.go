@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"text/template"
+
+	"github.com/nishanths/lyft-go"
+)
+
+// builtinTemplates are the named templates that can be used as the
+// value of the -template flag. The "status" and "driver" templates apply
+// to ride status output; "cost" and "eta" to the respective commands.
+var builtinTemplates = map[string]string{
+	"status": `{{.RideID}} {{status .RideStatus}}`,
+	"driver": `{{.Driver.FirstName}}: {{.Vehicle.Color}} {{.Vehicle.Make}} {{.Vehicle.Model}} ({{.Vehicle.LicensePlate}}), ETA {{.Origin.ETA}}`,
+	"cost":   `{{.DisplayName}}: {{cents .MinimumCost}}-{{cents .MaximumCost}}`,
+	"eta":    `{{.DisplayName}}: {{.ETA}}`,
+}
+
+var templateFuncs = template.FuncMap{
+	"cents":    formatCents,
+	"rideType": lyft.RideTypeDisplay,
+	"status":   lyft.RideStatusDisplay,
+}
+
+// parseOutputTemplate parses the value of the -template flag, which is
+// either the name of a built-in template or the template text itself.
+// It returns a nil template if s is empty.
+func parseOutputTemplate(s string) (*template.Template, error) {
+	if s == "" {
+		return nil, nil
+	}
+	if b, ok := builtinTemplates[s]; ok {
+		s = b
+	}
+	return template.New("output").Funcs(templateFuncs).Parse(s)
+}
+
+// executeTemplate writes the result of applying t to data to standard
+// output, followed by a newline if the result doesn't end with one.
+// It logs a fatal error if execution fails.
+func executeTemplate(t *template.Template, data interface{}) {
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		log.Fatalf("executing template: %s", err)
+	}
+	if !bytes.HasSuffix(buf.Bytes(), []byte("\n")) {
+		buf.WriteByte('\n')
+	}
+	os.Stdout.Write(buf.Bytes())
+}
+
+// Commands whose output can be formatted using -template or -json. The
+// ride create and rebook commands aren't included: they prompt and print
+// plain text before watching the ride, so their output can't be parsed.
+// Use ride status on the created ride instead.
+var (
+	templateCommands = map[string]bool{
+		"ride status": true,
+		"cost":        true,
+		"eta":         true,
+	}
+	jsonCommands = map[string]bool{
+		"ride status": true,
+		"ride list":   true,
+		"place show":  true,
+	}
+)
+
+// checkOutputFlags returns an error if -template or -json is set for a
+// command, named by the command-line arguments args, whose output they
+// don't apply to.
+func checkOutputFlags(args []string, tmpl, jsonOutput bool) error {
+	if len(args) == 0 {
+		return nil
+	}
+	cmd := args[0]
+	if (cmd == "ride" || cmd == "place") && len(args) > 1 {
+		cmd += " " + args[1]
+	}
+	if tmpl && !templateCommands[cmd] {
+		return fmt.Errorf("-template cannot be used with %s", cmd)
+	}
+	if jsonOutput && !jsonCommands[cmd] {
+		return fmt.Errorf("-json cannot be used with %s", cmd)
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/nishanths/lyft-go"
+)
+
+func TestBuiltinTemplatesParse(t *testing.T) {
+	for name := range builtinTemplates {
+		tmpl, err := parseOutputTemplate(name)
+		if err != nil {
+			t.Errorf("%s: %s", name, err)
+			continue
+		}
+		if tmpl == nil {
+			t.Errorf("%s: got nil template", name)
+		}
+	}
+}
+
+func TestParseOutputTemplateEmpty(t *testing.T) {
+	tmpl, err := parseOutputTemplate("")
+	if err != nil || tmpl != nil {
+		t.Errorf("got (%v, %v), want (nil, nil)", tmpl, err)
+	}
+}
+
+func TestParseOutputTemplateInvalid(t *testing.T) {
+	if _, err := parseOutputTemplate("{{.RideID"); err == nil {
+		t.Error("expected error for unterminated action")
+	}
+	if _, err := parseOutputTemplate("{{nosuchfunc .RideID}}"); err == nil {
+		t.Error("expected error for undefined function")
+	}
+}
+
+func TestExecuteBuiltinTemplates(t *testing.T) {
+	cost := lyft.CostEstimate{DisplayName: "Lyft", MinimumCost: 1050, MaximumCost: 1575, Valid: true}
+	eta := lyft.ETAEstimate{DisplayName: "Lyft", ETA: 4 * time.Minute, Valid: true}
+	ride := lyft.RideDetail{
+		RideID:     "123",
+		RideStatus: lyft.StatusPickedUp,
+		Origin:     lyft.RideLocation{ETA: 2 * time.Minute},
+		Driver:     lyft.Person{FirstName: "Sam"},
+		Vehicle:    lyft.Vehicle{Make: "Toyota", Model: "Prius", Color: "Blue", LicensePlate: "7ABC123"},
+	}
+
+	tests := []struct {
+		name string
+		data interface{}
+		want string
+	}{
+		{"cost", cost, "Lyft: $10.50-$15.75"},
+		{"eta", eta, "Lyft: 4m0s"},
+		{"status", ride, "123 Picked up"},
+		{"driver", ride, "Sam: Blue Toyota Prius (7ABC123), ETA 2m0s"},
+		{"{{rideType .RideType}} {{.RideID}}", lyft.RideDetail{RideID: "9", RideType: lyft.RideTypeLine}, "Lyft Line 9"},
+	}
+	for _, tt := range tests {
+		tmpl, err := parseOutputTemplate(tt.name)
+		if err != nil {
+			t.Fatalf("%s: %s", tt.name, err)
+		}
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, tt.data); err != nil {
+			t.Errorf("%s: %s", tt.name, err)
+			continue
+		}
+		if got := buf.String(); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCheckOutputFlags(t *testing.T) {
+	tests := []struct {
+		args       []string
+		tmpl, json bool
+		ok         bool
+	}{
+		{[]string{"ride", "status", "1"}, true, false, true},
+		{[]string{"ride", "status", "1"}, false, true, true},
+		{[]string{"cost"}, true, false, true},
+		{[]string{"eta"}, true, false, true},
+		{[]string{"ride", "list"}, false, true, true},
+		{[]string{"place", "show"}, false, true, true},
+		{[]string{"history"}, false, false, true},
+
+		{[]string{"ride", "receipt", "1"}, true, false, false},
+		{[]string{"ride", "create"}, true, false, false},
+		{[]string{"ride", "create"}, false, true, false},
+		{[]string{"ride", "rebook", "1"}, false, true, false},
+		{[]string{"ride", "list"}, true, false, false},
+		{[]string{"history"}, true, false, false},
+		{[]string{"place", "show"}, true, false, false},
+		{[]string{"cost"}, false, true, false},
+		{[]string{"eta"}, false, true, false},
+	}
+	for _, tt := range tests {
+		err := checkOutputFlags(tt.args, tt.tmpl, tt.json)
+		if (err == nil) != tt.ok {
+			t.Errorf("%v (template=%t, json=%t): got error %v, want ok=%t", tt.args, tt.tmpl, tt.json, err, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/nishanths/lyft-go"
+	"github.com/nishanths/lyft-go/auth"
+)
+
+// cmdRideUpdate changes the end location of a ride. The end location is
+// the only ride parameter that the Lyft API allows changing after the
+// ride has been requested.
+func cmdRideUpdate(args []string, flags Flags) {
+	if len(args) == 0 {
+		log.Fatalf("must specify a <ride-id> to update")
+	}
+
+	inter := getInternal(flags)
+	if err := requireScope(inter, auth.RidesRequest); err != nil {
+		log.Fatal(err)
+	}
+	lyftClient := newLyftClient(inter)
+
+	end, err := endLocation(flags, "Enter new end location (street address or lat,lng): ")
+	if err != nil {
+		log.Fatal(err)
+	}
+	loc, err := destination(end)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if flags.dryRun {
+		os.Exit(0)
+	}
+
+	dest, h, err := setDestination(lyftClient, inter, args[0], loc)
+	if err != nil {
+		log.Fatalf("failed to update ride %s: %s", args[0], describeError(err, h, inter.Sandbox))
+	}
+
+	w := standardTabWriter()
+	fmt.Fprintf(w, "End:\t%s\n", googleMapsURL(dest.Latitude, dest.Longitude))
+	if dest.Address != "" {
+		fmt.Fprintf(w, "\t%s\n", dest.Address)
+	}
+	w.Flush()
+	os.Exit(0)
+}
+
+// destination returns the location to send as the new end location of a
+// ride. It returns an error if end is nil, that is, if no end location was
+// entered.
+func destination(end *Location) (lyft.Location, error) {
+	if end == nil {
+		return lyft.Location{}, errors.New("must specify an end location to update the ride")
+	}
+	return lyft.Location{Latitude: end.Lat, Longitude: end.Lng, Address: end.Address}, nil
+}
+
+// setDestination changes the end location of the ride to loc, and returns
+// the end location as updated by Lyft.
+func setDestination(lyftClient *lyft.Client, inter Internal, rideID string, loc lyft.Location) (lyft.Location, http.Header, error) {
+	var dest lyft.Location
+	var h http.Header
+	err := withRefresh(lyftClient, &inter, func() (err error) {
+		dest, h, err = lyftClient.SetDestination(rideID, loc)
+		return err
+	})
+	return dest, h, err
+}
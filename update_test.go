@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nishanths/lyft-go"
+)
+
+func TestDestination(t *testing.T) {
+	if _, err := destination(nil); err == nil {
+		t.Error("no end location: expected error")
+	}
+
+	got, err := destination(&Location{Lat: 37.7763, Lng: -122.3918, Address: "185 Berry St"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := lyft.Location{Latitude: 37.7763, Longitude: -122.3918, Address: "185 Berry St"}
+	if got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestSetDestination(t *testing.T) {
+	var method, path string
+	var sent lyft.Location
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, path = r.Method, r.URL.Path
+		if err := json.NewDecoder(r.Body).Decode(&sent); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeMockJSON(w, http.StatusOK, `{"lat": 37.7763, "lng": -122.3918, "address": "185 Berry St, San Francisco, CA"}`)
+	}))
+	defer srv.Close()
+
+	inter := Internal{AccessToken: "token", noPersist: true, baseURL: srv.URL}
+	loc := lyft.Location{Latitude: 37.7763, Longitude: -122.3918, Address: "185 Berry St"}
+	dest, _, err := setDestination(newLyftClient(inter), inter, mockRideID, loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if method != "PUT" || path != "/v1/rides/"+mockRideID+"/destination" {
+		t.Errorf("got request %s %s", method, path)
+	}
+	if sent != loc {
+		t.Errorf("sent %+v, want %+v", sent, loc)
+	}
+	if dest.Address != "185 Berry St, San Francisco, CA" {
+		t.Errorf("got destination %+v", dest)
+	}
+}
+
+func TestSetDestinationError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeMockJSON(w, http.StatusNotFound, `{"error": "not_found"}`)
+	}))
+	defer srv.Close()
+
+	inter := Internal{AccessToken: "token", noPersist: true, baseURL: srv.URL}
+	_, _, err := setDestination(newLyftClient(inter), inter, "unknown", lyft.Location{Latitude: 1, Longitude: 2})
+	if se, ok := err.(*lyft.StatusError); !ok || se.StatusCode != http.StatusNotFound {
+		t.Errorf("got error %v, want a 404 status error", err)
+	}
+}